@@ -0,0 +1,24 @@
+--- a/services/auth/auth.go
++++ b/services/auth/auth.go
+@@ -XXX,XXX +XXX,XXX @@
+ 
+ var globalVars = sync.OnceValue(func() *globalVarsStruct {
+ 	return &globalVarsStruct{
+-		gitRawOrAttachPathRe: regexp.MustCompile(`^/[-.\w]+/[-.\w]+/(?:(?:git-(?:(?:upload)|(?:receive))-pack$)|(?:info/refs$)|(?:HEAD$)|(?:objects/)|(?:raw/)|(?:releases/download/)|(?:attachments/))`),
++		gitRawOrAttachPathRe: regexp.MustCompile(`^/[-.\w]+/[-.\w]+/(?:(?:git-(?:(?:upload)|(?:receive))-pack$)|(?:git-upload-archive$)|(?:info/refs$)|(?:HEAD$)|(?:objects/)|(?:raw/)|(?:releases/download/)|(?:attachments/))`),
+ 		lfsPathRe:            regexp.MustCompile(`^/[-.\w]+/[-.\w]+/info/lfs/`),
+ 		archivePathRe:        regexp.MustCompile(`^/[-.\w]+/[-.\w]+/archive/`),
+ 		feedPathRe:           regexp.MustCompile(`^/[-.\w]+(/[-.\w]+)?\.(rss|atom)$`), // "/owner.rss" or "/owner/repo.atom"
+--- a/services/auth/auth_test.go
++++ b/services/auth/auth_test.go
+@@ -XXX,XXX +XXX,XXX @@
+ 			"/owner/repo/git-receive-pack",
+ 			true,
+ 		},
++		{
++			"/owner/repo/git-upload-archive",
++			true,
++		},
+ 		{
+ 			"/owner/repo/info/refs",
+ 			true,
@@ -0,0 +1,31 @@
+--- a/services/repository/delete.go
++++ b/services/repository/delete.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	actions_service "code.gitea.io/gitea/services/actions"
+ 	asymkey_service "code.gitea.io/gitea/services/asymkey"
+ 	issue_service "code.gitea.io/gitea/services/issue"
++	download_service "code.gitea.io/gitea/services/repository/download"
+ 
+ 	"xorm.io/builder"
+ )
+@@ -XXX,XXX +XXX,XXX @@
+ 		&git_model.LFSLock{RepoID: repoID},
+ 		&repo_model.LanguageStat{RepoID: repoID},
+ 		&repo_model.RepoLicense{RepoID: repoID},
++		&repo_model.ArchivePolicy{RepoID: repoID},
++		&repo_model.ArchiveExport{RepoID: repoID},
+ 		&issues_model.Milestone{RepoID: repoID},
+ 		&repo_model.Mirror{RepoID: repoID},
+ 		&activities_model.Notification{RepoID: repoID},
+@@ -XXX,XXX +XXX,XXX @@
+ 		system_model.RemoveStorageWithNotice(ctx, storage.RepoArchives, "Delete repo archive file", archive)
+ 	}
+ 
++	// Remove folder archives and their statuses
++	download_service.DeleteRepositoryArchives(ctx, repoID)
++
+ 	// Remove lfs objects
++	download_service.DeleteRepositoryLFSProblems(ctx, repoID)
+ 	for _, lfsObj := range lfsPaths {
+ 		system_model.RemoveStorageWithNotice(ctx, storage.LFS, "Delete orphaned LFS file", lfsObj)
+ 	}
@@ -0,0 +1,33 @@
+--- a/routers/web/repo/githttp.go
++++ b/routers/web/repo/githttp.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	"code.gitea.io/gitea/modules/structs"
+ 	"code.gitea.io/gitea/services/context"
+ 	repo_service "code.gitea.io/gitea/services/repository"
++	download_service "code.gitea.io/gitea/services/repository/download"
+ 
+ 	"github.com/go-chi/cors"
+ )
+@@ -XXX,XXX +XXX,XXX @@
+ 					ctx.PlainText(http.StatusNotFound, "Repository not found")
+ 					return nil
+ 				}
++
++				if !isWiki && !p.IsAdmin() && download_service.IsBrowseOnlyUser(ctx, ctx.Doer, repo) {
++					ctx.PlainText(http.StatusForbidden, "browse-only access does not allow git operations")
++					return nil
++				}
+ 			}
+ 
+ 			if !isPull && repo.IsMirror {
+@@ -XXX,XXX +XXX,XXX @@
+ 	}
+ 	setHeaderNoCache(ctx)
+ 	service := getServiceType(ctx)
++	if service == "upload-archive" {
++		writeUploadArchiveAdvertisement(ctx)
++		return
++	}
+ 	cmd, err := prepareGitCmdWithAllowedService(service)
+ 	if err == nil {
+ 		if protocol := ctx.Req.Header.Get("Git-Protocol"); protocol != "" && safeGitProtocolHeader.MatchString(protocol) {
@@ -0,0 +1,19 @@
+--- a/web_src/js/index-domready.ts
++++ b/web_src/js/index-domready.ts
+@@ -XXX,XXX +XXX,XXX @@
+ import {initSshKeyFormParser} from './features/sshkey-helper.ts';
+ import {initUserSettings} from './features/user-settings.ts';
+ import {initRepoActivityTopAuthorsChart, initRepoArchiveLinks} from './features/repo-common.ts';
++import {initRepoDownloadFolderConfirm, initRepoDownloadFolderParts} from './features/repo-download-folder.ts';
+ import {initRepoMigrationStatusChecker} from './features/repo-migrate.ts';
+ import {initRepoDiffView} from './features/repo-diff.ts';
+ import {initOrgTeam} from './features/org-team.ts';
+@@ -XXX,XXX +XXX,XXX @@
+   initBranchSelectorTabs,
+   initRepoEllipsisButton,
+   initRepoDiffCommitBranchesAndTags,
++  initRepoDownloadFolderConfirm,
++  initRepoDownloadFolderParts,
+   initRepoEditor,
+   initRepoGraphGit,
+   initRepoIssueContentHistory,
@@ -0,0 +1,18 @@
+--- a/routers/init.go
++++ b/routers/init.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	release_service "code.gitea.io/gitea/services/release"
+ 	repo_service "code.gitea.io/gitea/services/repository"
+ 	"code.gitea.io/gitea/services/repository/archiver"
++	download_service "code.gitea.io/gitea/services/repository/download"
+ 	"code.gitea.io/gitea/services/task"
+ 	"code.gitea.io/gitea/services/uinotification"
+ 	"code.gitea.io/gitea/services/webhook"
+@@ -XXX,XXX +XXX,XXX @@
+ 	mustInit(feed_service.Init)
+ 	mustInit(uinotification.Init)
+ 	mustInitCtx(ctx, archiver.Init)
++	mustInitCtx(ctx, download_service.Init)
+ 
+ 	highlight.NewContext()
+ 	external.RegisterRenderers()
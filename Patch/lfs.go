@@ -0,0 +1,48 @@
+--- a/routers/web/repo/setting/lfs.go
++++ b/routers/web/repo/setting/lfs.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	ctx.Data["PageIsSettingsLFS"] = true
+ 	ctx.Data["LFSFilesLink"] = ctx.Repo.RepoLink + "/settings/lfs"
+ 
++	// "missing" only lists the pointers whose object cannot be downloaded from this repository
++	filter := ctx.FormString("filter")
++	if filter != "missing" {
++		filter = ""
++	}
++	ctx.Data["Filter"] = filter
++
+ 	var err error
+ 	err = func() error {
+ 		pointerChan := make(chan lfs.PointerBlob)
+@@ -XXX,XXX +XXX,XXX @@
+ 		go lfs.SearchPointerBlobs(ctx, ctx.Repo.GitRepo, pointerChan, errChan)
+ 
+ 		numPointers := 0
+-		var numAssociated, numNoExist, numAssociatable int
++		var numAssociated, numNoExist, numAssociatable, numMissing int
+ 
+ 		type pointerResult struct {
+ 			SHA          string
+@@ -XXX,XXX +XXX,XXX @@
+ 			if result.Associatable {
+ 				numAssociatable++
+ 			}
++			missing := !result.InRepo || !result.Exists
++			if missing {
++				numMissing++
++			}
+ 
++			if filter == "missing" && !missing {
++				continue
++			}
+ 			results = append(results, result)
+ 		}
+ 
+@@ -XXX,XXX +XXX,XXX @@
+ 		ctx.Data["NumAssociated"] = numAssociated
+ 		ctx.Data["NumAssociatable"] = numAssociatable
+ 		ctx.Data["NumNoExist"] = numNoExist
++		ctx.Data["NumMissing"] = numMissing
+ 		ctx.Data["NumNotAssociated"] = numPointers - numAssociated
+ 
+ 		return nil
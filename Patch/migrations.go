@@ -0,0 +1,15 @@
+--- a/models/migrations/migrations.go
++++ b/models/migrations/migrations.go
+@@ -XXX,XXX +XXX,XXX @@
+ 		// Gitea 1.24.0 ends at database version 321
+ 		newMigration(321, "Use LONGTEXT for some columns and fix review_state.updated_files column", v1_25.UseLongTextInSomeColumnsAndFixBugs),
+ 		newMigration(322, "Extend comment tree_path length limit", v1_25.ExtendCommentTreePathLength),
++		newMigration(323, "Add download_usage table", v1_25.AddDownloadUsageTable),
++		newMigration(324, "Add browse_only to team", v1_25.AddBrowseOnlyToTeam),
++		newMigration(325, "Add repo_archive_policy table", v1_25.AddRepoArchivePolicyTable),
++		newMigration(326, "Add zip_aes to repo_archive_policy", v1_25.AddZipAESToRepoArchivePolicy),
++		newMigration(327, "Add repo_archive_export table", v1_25.AddRepoArchiveExportTable),
++		newMigration(328, "Add download_audit table", v1_25.AddDownloadAuditTable),
+ 	}
+ 	return preparedMigrations
+ }
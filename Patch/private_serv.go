@@ -0,0 +1,69 @@
+--- a/routers/private/serv.go
++++ b/routers/private/serv.go
+@@ -XXX,XXX +XXX,XXX @@
+ package private
+ 
+ import (
++	"errors"
+ 	"fmt"
+ 	"net/http"
+ 	"strings"
+ 
+ 	asymkey_model "code.gitea.io/gitea/models/asymkey"
++	"code.gitea.io/gitea/models/organization"
+ 	"code.gitea.io/gitea/models/perm"
+ 	access_model "code.gitea.io/gitea/models/perm/access"
+ 	repo_model "code.gitea.io/gitea/models/repo"
+@@ -XXX,XXX +XXX,XXX @@
+ 	"code.gitea.io/gitea/modules/log"
+ 	"code.gitea.io/gitea/modules/private"
+ 	"code.gitea.io/gitea/modules/setting"
++	"code.gitea.io/gitea/modules/util"
+ 	"code.gitea.io/gitea/services/context"
+ 	repo_service "code.gitea.io/gitea/services/repository"
++	download_service "code.gitea.io/gitea/services/repository/download"
+ 	wiki_service "code.gitea.io/gitea/services/wiki"
+ )
+ 
+@@ -XXX,XXX +XXX,XXX @@
+ 				})
+ 				return
+ 			}
++
++			// members of browse-only teams read the code in the web UI only
++			if unitType == unit.TypeCode && !perm.IsAdmin() && owner.IsOrganization() {
++				browseOnly, err := organization.IsUserBrowseOnlyInRepo(ctx, owner.ID, user.ID, repo.ID)
++				if err != nil {
++					log.Error("Unable to check the browse-only teams of %-v in %-v Error: %v", user, repo, err)
++					ctx.JSON(http.StatusInternalServerError, private.Response{
++						Err: fmt.Sprintf("Unable to check the browse-only teams of user %d:%s in %s/%s Error: %v", user.ID, user.Name, results.OwnerName, results.RepoName, err),
++					})
++					return
++				}
++				if browseOnly {
++					ctx.JSON(http.StatusForbidden, private.Response{
++						UserMsg: fmt.Sprintf("User: %d:%s has browse-only access to %s/%s, which does not allow git operations.", user.ID, user.Name, ownerName, repoName),
++					})
++					return
++				}
++			}
++		}
++	}
++
++	// upload-archive generates archives outside of the web server, which enforces the archive limits
++	if repoExist && verb == git.CmdVerbUploadArchive {
++		if err := download_service.CheckUploadArchiveOverSSH(ctx, repo, user); err != nil {
++			if errors.Is(err, util.ErrPermissionDenied) {
++				ctx.JSON(http.StatusForbidden, private.Response{
++					UserMsg: err.Error(),
++				})
++				return
++			}
++			log.Error("Unable to check the upload-archive request of %-v in %-v Error: %v", user, repo, err)
++			ctx.JSON(http.StatusInternalServerError, private.Response{
++				Err: fmt.Sprintf("Unable to check the upload-archive request in %s/%s Error: %v", results.OwnerName, results.RepoName, err),
++			})
++			return
+ 		}
+ 	}
+ 
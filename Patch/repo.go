@@ -0,0 +1,22 @@
+--- a/routers/web/repo/repo.go
++++ b/routers/web/repo/repo.go
+@@ -XXX,XXX +XXX,XXX @@
+ 
+ // Download an archive of a repository
+ func Download(ctx *context.Context) {
++	if !checkArchivePolicy(ctx, true) {
++		return
++	}
+ 	aReq, err := archiver_service.NewRequest(ctx.Repo.Repository.ID, ctx.Repo.GitRepo, ctx.PathParam("*"))
+ 	if err != nil {
+ 		if errors.Is(err, archiver_service.ErrUnknownArchiveFormat{}) {
+@@ -XXX,XXX +XXX,XXX @@
+ // a request that's already in-progress, but the archiver service will just
+ // kind of drop it on the floor if this is the case.
+ func InitiateDownload(ctx *context.Context) {
+-	if setting.Repository.StreamArchives {
++	// the archive link itself shows the sign-in or captcha page of the archive policy
++	if setting.Repository.StreamArchives || evalArchivePolicy(ctx) != archivePolicyPass {
+ 		ctx.JSON(http.StatusOK, map[string]any{
+ 			"complete": true,
+ 		})
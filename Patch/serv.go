@@ -0,0 +1,65 @@
+--- a/cmd/serv.go
++++ b/cmd/serv.go
+@@ -XXX,XXX +XXX,XXX @@
+ package cmd
+ 
+ import (
++	"bytes"
+ 	"context"
+ 	"fmt"
++	"io"
+ 	"net/url"
+ 	"os"
+ 	"os/exec"
+@@ -XXX,XXX +XXX,XXX @@
+ 		return nil
+ 	}
+ 
++	var stdin io.Reader = os.Stdin
++	if verb == git.CmdVerbUploadArchive {
++		// check the requested tree-ish and paths like a folder download before handing them to git
++		args, err := checkUploadArchiveArguments(ctx, repoPath)
++		if err != nil {
++			return fail(ctx, err.Error(), "Invalid upload-archive request: %v", err)
++		}
++		stdin = args
++	}
++
+ 	var command *exec.Cmd
+ 	gitBinPath := filepath.Dir(gitcmd.GitExecutable) // e.g. /usr/bin
+ 	gitBinVerb := filepath.Join(gitBinPath, verb)    // e.g. /usr/bin/git-upload-pack
+@@ -XXX,XXX +XXX,XXX @@
+ 	process.SetSysProcAttribute(command)
+ 	command.Dir = setting.RepoRootPath
+ 	command.Stdout = os.Stdout
+-	command.Stdin = os.Stdin
++	command.Stdin = stdin
+ 	command.Stderr = os.Stderr
+ 	command.Env = append(command.Env, os.Environ()...)
+ 	command.Env = append(command.Env,
+@@ -XXX,XXX +XXX,XXX @@
+ 
+ 	return nil
+ }
++
++// checkUploadArchiveArguments reads the arguments of an upload-archive request from stdin and validates them
++// against the repository, it returns them to be sent to git
++func checkUploadArchiveArguments(ctx context.Context, repoPath string) (io.Reader, error) {
++	args, err := git.ReadUploadArchiveArguments(io.LimitReader(os.Stdin, 64*1024))
++	if err != nil {
++		return nil, err
++	}
++	gitRepo, err := git.OpenRepository(ctx, filepath.Join(setting.RepoRootPath, repoPath))
++	if err != nil {
++		return nil, err
++	}
++	defer gitRepo.Close()
++	if err = git.ValidateUploadArchiveArguments(gitRepo, args); err != nil {
++		return nil, err
++	}
++	var buf bytes.Buffer
++	if err = git.WriteUploadArchiveArguments(&buf, args); err != nil {
++		return nil, err
++	}
++	return &buf, nil
++}
@@ -0,0 +1,60 @@
+--- a/services/cron/tasks_basic.go
++++ b/services/cron/tasks_basic.go
+@@ -XXX,XXX +XXX,XXX @@
+ 
+ 	"code.gitea.io/gitea/models"
+ 	git_model "code.gitea.io/gitea/models/git"
++	repo_model "code.gitea.io/gitea/models/repo"
+ 	user_model "code.gitea.io/gitea/models/user"
+ 	"code.gitea.io/gitea/models/webhook"
+ 	"code.gitea.io/gitea/modules/git/gitcmd"
+ 	"code.gitea.io/gitea/modules/setting"
++	"code.gitea.io/gitea/modules/timeutil"
+ 	"code.gitea.io/gitea/services/auth"
+ 	"code.gitea.io/gitea/services/migrations"
+ 	mirror_service "code.gitea.io/gitea/services/mirror"
+ 	packages_cleanup_service "code.gitea.io/gitea/services/packages/cleanup"
+ 	repo_service "code.gitea.io/gitea/services/repository"
+ 	archiver_service "code.gitea.io/gitea/services/repository/archiver"
++	download_service "code.gitea.io/gitea/services/repository/download"
+ )
+ 
+ func registerUpdateMirrorTask() {
+@@ -XXX,XXX +XXX,XXX @@
+ 	})
+ }
+ 
++func registerCleanupDownloadAudit() {
++	RegisterTaskFatal("cleanup_download_audit", &BaseConfig{
++		Enabled:    true,
++		RunAtStart: true,
++		Schedule:   "@midnight",
++	}, func(ctx context.Context, _ *user_model.User, _ Config) error {
++		return repo_model.DeleteDownloadAuditsBefore(ctx, timeutil.TimeStamp(time.Now().Add(-download_service.Setting().AuditLogRetention).Unix()))
++	})
++}
++
++func registerRetryFolderArchiveExports() {
++	RegisterTaskFatal("retry_folder_archive_exports", &BaseConfig{
++		Enabled:    true,
++		RunAtStart: true,
++		Schedule:   "@every 1m",
++	}, func(ctx context.Context, _ *user_model.User, _ Config) error {
++		return download_service.RetryExports(ctx)
++	})
++}
++
+ func initBasicTasks() {
+ 	if setting.Mirror.Enabled {
+ 		registerUpdateMirrorTask()
+@@ -XXX,XXX +XXX,XXX @@
+ 		registerCleanupPackages()
+ 	}
+ 	registerSyncRepoLicenses()
++	if s := download_service.Setting(); s.AuditLogEnabled && s.AuditLogRetention > 0 {
++		registerCleanupDownloadAudit()
++	}
++	if download_service.Setting().ExportEnabled {
++		registerRetryFolderArchiveExports()
++	}
+ }
@@ -0,0 +1,48 @@
+--- a/services/cron/tasks_extended.go
++++ b/services/cron/tasks_extended.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	asymkey_service "code.gitea.io/gitea/services/asymkey"
+ 	repo_service "code.gitea.io/gitea/services/repository"
+ 	archiver_service "code.gitea.io/gitea/services/repository/archiver"
++	download_service "code.gitea.io/gitea/services/repository/download"
+ 	user_service "code.gitea.io/gitea/services/user"
+ )
+ 
+@@ -XXX,XXX +XXX,XXX @@
+ 	})
+ }
+ 
++// LFSStorageScanConfig is the [cron.lfs_storage_scan] section, VerifyChecksums reads every object to compare
++// its SHA-256 with its OID instead of only checking that it exists with the right size
++type LFSStorageScanConfig struct {
++	BaseConfig
++	VerifyChecksums bool
++}
++
++func registerLFSStorageScan() {
++	if !setting.LFS.StartServer {
++		return
++	}
++
++	RegisterTaskFatal("lfs_storage_scan", &LFSStorageScanConfig{
++		BaseConfig: BaseConfig{
++			Enabled:    false,
++			RunAtStart: false,
++			Schedule:   "@every 168h",
++		},
++		VerifyChecksums: true,
++	}, func(ctx context.Context, _ *user_model.User, config Config) error {
++		return download_service.ScanLFSStorage(ctx, config.(*LFSStorageScanConfig).VerifyChecksums)
++	})
++}
++
+ func registerRebuildIssueIndexer() {
+ 	RegisterTaskFatal("rebuild_issue_indexer", &BaseConfig{
+ 		Enabled:    false,
+@@ -XXX,XXX +XXX,XXX @@
+ 	registerUpdateGiteaChecker()
+ 	registerDeleteOldSystemNotices()
+ 	registerGCLFS()
++	registerLFSStorageScan()
+ 	registerRebuildIssueIndexer()
+ }
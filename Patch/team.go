@@ -0,0 +1,50 @@
+--- a/models/organization/team.go
++++ b/models/organization/team.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	Units                   []*TeamUnit `xorm:"-"`
+ 	IncludesAllRepositories bool        `xorm:"NOT NULL DEFAULT false"`
+ 	CanCreateOrgRepo        bool        `xorm:"NOT NULL DEFAULT false"`
++	// BrowseOnly members read the code in the web UI, but cannot clone it or download archives
++	BrowseOnly bool `xorm:"NOT NULL DEFAULT false"`
+ }
+ 
+ func init() {
+--- a/routers/web/org/teams.go
++++ b/routers/web/org/teams.go
+@@ -XXX,XXX +XXX,XXX @@
+ 		AccessMode:              teamPermission,
+ 		IncludesAllRepositories: includesAllRepositories,
+ 		CanCreateOrgRepo:        form.CanCreateOrgRepo,
++		BrowseOnly:              form.BrowseOnly,
+ 	}
+ 
+ 	units := make([]*org_model.TeamUnit, 0, len(unitPerms))
+@@ -XXX,XXX +XXX,XXX @@
+ 			t.IncludesAllRepositories = includesAllRepositories
+ 		}
+ 		t.CanCreateOrgRepo = form.CanCreateOrgRepo
++		t.BrowseOnly = form.BrowseOnly
+ 	} else {
+ 		t.CanCreateOrgRepo = true
+ 	}
+--- a/services/forms/org.go
++++ b/services/forms/org.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	Permission       string
+ 	RepoAccess       string
+ 	CanCreateOrgRepo bool
++	BrowseOnly       bool
+ }
+ 
+ // Validate validates the fields
+--- a/services/org/team.go
++++ b/services/org/team.go
+@@ -XXX,XXX +XXX,XXX @@
+ 
+ 		sess := db.GetEngine(ctx)
+ 		if _, err = sess.ID(t.ID).Cols("name", "lower_name", "description",
+-			"can_create_org_repo", "authorize", "includes_all_repositories").Update(t); err != nil {
++			"can_create_org_repo", "browse_only", "authorize", "includes_all_repositories").Update(t); err != nil {
+ 			return fmt.Errorf("update: %w", err)
+ 		}
+ 
@@ -0,0 +1,102 @@
+--- a/models/webhook/webhook.go
++++ b/models/webhook/webhook.go
+@@ -XXX,XXX +XXX,XXX @@
+ 
+ func (w *Webhook) HasEvent(evt webhook_module.HookEventType) bool {
+ 	if w.SendEverything {
+-		return true
++		// downloads are frequent, they are not part of "everything" unless chosen
++		return evt != webhook_module.HookEventRepositoryDownload || w.HookEvents[evt]
+ 	}
+ 	if w.PushOnly {
+ 		return evt == webhook_module.HookEventPush
+@@ -XXX,XXX +XXX,XXX @@
+ 	if w.SendEverything {
+ 		events := make([]string, 0, len(webhook_module.AllEvents()))
+ 		for _, evt := range webhook_module.AllEvents() {
++			if evt == webhook_module.HookEventRepositoryDownload && !w.HookEvents[evt] {
++				continue
++			}
+ 			events = append(events, string(evt))
+ 		}
+ 		return events
+--- a/modules/structs/hook.go
++++ b/modules/structs/hook.go
+@@ -XXX,XXX +XXX,XXX @@
+ func (p *WorkflowJobPayload) JSONPayload() ([]byte, error) {
+ 	return json.MarshalIndent(p, "", "  ")
+ }
++
++// RepositoryDownloadPayload represents a payload information of repository download event.
++type RepositoryDownloadPayload struct {
++	// The kind of download: folder, folder_part, tree, archive or release
++	Kind string `json:"kind"`
++	// The ref the content was downloaded from
++	Ref string `json:"ref"`
++	// The SHA hash of the downloaded commit
++	Commit string `json:"commit"`
++	// The downloaded path, the attachment UUID or file name for release assets
++	Path string `json:"path"`
++	// The format of the download
++	Format string `json:"format"`
++	// The number of bytes sent
++	Size int64 `json:"size"`
++	// The repository the content was downloaded from
++	Repository *Repository `json:"repository"`
++	// The user who downloaded the content, absent for anonymous downloads
++	Sender *User `json:"sender,omitempty"`
++}
++
++// JSONPayload implements Payload
++func (p *RepositoryDownloadPayload) JSONPayload() ([]byte, error) {
++	return json.MarshalIndent(p, "", "  ")
++}
+--- a/modules/webhook/type.go
++++ b/modules/webhook/type.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	HookEventRelease                   HookEventType = "release"
+ 	HookEventPackage                   HookEventType = "package"
+ 	HookEventStatus                    HookEventType = "status"
++	// HookEventRepositoryDownload is only sent to hooks that send everything when they opted in
++	HookEventRepositoryDownload HookEventType = "repository_download"
+ 	// once a new event added here, please also added to AllEvents() function
+ 
+ 	// FIXME: This event should be a group of pull_request_review_xxx events
+@@ -XXX,XXX +XXX,XXX @@
+ 		HookEventRelease,
+ 		HookEventPackage,
+ 		HookEventStatus,
++		HookEventRepositoryDownload,
+ 		HookEventWorkflowRun,
+ 		HookEventWorkflowJob,
+ 	}
+--- a/routers/api/v1/utils/hook.go
++++ b/routers/api/v1/utils/hook.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	hookEvents[webhook_module.HookEventRelease] = util.SliceContainsString(events, string(webhook_module.HookEventRelease), true)
+ 	hookEvents[webhook_module.HookEventPackage] = util.SliceContainsString(events, string(webhook_module.HookEventPackage), true)
+ 	hookEvents[webhook_module.HookEventStatus] = util.SliceContainsString(events, string(webhook_module.HookEventStatus), true)
++	hookEvents[webhook_module.HookEventRepositoryDownload] = util.SliceContainsString(events, string(webhook_module.HookEventRepositoryDownload), true)
+ 	hookEvents[webhook_module.HookEventWorkflowRun] = util.SliceContainsString(events, string(webhook_module.HookEventWorkflowRun), true)
+ 	hookEvents[webhook_module.HookEventWorkflowJob] = util.SliceContainsString(events, string(webhook_module.HookEventWorkflowJob), true)
+ 
+--- a/routers/web/repo/setting/webhook.go
++++ b/routers/web/repo/setting/webhook.go
+@@ -XXX,XXX +XXX,XXX @@
+ 			webhook_module.HookEventRepository:               form.Repository,
+ 			webhook_module.HookEventPackage:                  form.Package,
+ 			webhook_module.HookEventStatus:                   form.Status,
++			webhook_module.HookEventRepositoryDownload:       form.RepositoryDownload,
+ 			webhook_module.HookEventWorkflowRun:              form.WorkflowRun,
+ 			webhook_module.HookEventWorkflowJob:              form.WorkflowJob,
+ 		},
+--- a/services/forms/repo_form.go
++++ b/services/forms/repo_form.go
+@@ -XXX,XXX +XXX,XXX @@
+ 	Release                  bool
+ 	Package                  bool
+ 	Status                   bool
++	RepositoryDownload       bool
+ 	WorkflowRun              bool
+ 	WorkflowJob              bool
+ 	Active                   bool
@@ -5,11 +5,11 @@
 package repo
 
 import (
-    "bytes"
+    "errors"
     "fmt"
-    "io"
+    "net/http"
     "net/url"
-    "os/exec" 
+    "os"
     "path"
     "strings"
     "time"
@@ -23,6 +23,9 @@ import (
     "code.gitea.io/gitea/modules/storage"
     "code.gitea.io/gitea/routers/common"
     "code.gitea.io/gitea/services/context"
+    download_service "code.gitea.io/gitea/services/repository/download"
+
+    "github.com/klauspost/compress/gzhttp"
 )
 
 // ServeBlobOrLFS download a git.Blob redirecting to LFS if necessary
@@ -53,8 +56,17 @@ func ServeBlobOrLFS(ctx *context.Context, blob *git.Blob, lastModified *time.Tim
                 log.Error("ServeBlobOrLFS: Close: %v", err)
             }
             closed = true
+            if download_service.Setting().LFSMissingObject == download_service.LFSMissingObjectError {
+                serveLFSMissing(ctx, blob, pointer)
+                return nil
+            }
+            lfsServes.WithLabelValues("pointer").Inc()
+            setBlobDigestHeaders(ctx, blob)
             return common.ServeBlob(ctx.Base, ctx.Repo.Repository, ctx.Repo.TreePath, blob, lastModified)
         }
+        // the OID of an LFS object is the SHA-256 of its content
+        setObjectIDHeader(ctx.Resp, blob.ID)
+        setSHA256DigestHeaders(ctx.Resp, pointer.Oid)
         if httpcache.HandleGenericETagCache(ctx.Req, ctx.Resp, `"`+pointer.Oid+`"`) {
             return nil
         }
@@ -62,7 +74,8 @@ func ServeBlobOrLFS(ctx *context.Context, blob *git.Blob, lastModified *time.Tim
         if setting.LFS.Storage.ServeDirect() {
             // If we have a signed url (S3, object storage, blob storage), redirect to this directly.
             u, err := storage.LFS.URL(pointer.RelativePath(), blob.Name(), ctx.Req.Method, nil)
-            if u != nil && err == nil {
+            if u != nil && err == nil && allowRedirectedDownload(ctx, meta.Size) {
+                lfsServes.WithLabelValues("redirect").Inc()
                 ctx.Redirect(u.String())
                 return nil
             }
@@ -70,6 +83,10 @@ func ServeBlobOrLFS(ctx *context.Context, blob *git.Blob, lastModified *time.Tim
 
         lfsDataRc, err := lfs.ReadMetaObject(meta.Pointer)
         if err != nil {
+            if errors.Is(err, os.ErrNotExist) && download_service.Setting().LFSMissingObject == download_service.LFSMissingObjectError {
+                serveLFSMissing(ctx, blob, pointer)
+                return nil
+            }
             return err
         }
         defer func() {
@@ -77,6 +94,13 @@ func ServeBlobOrLFS(ctx *context.Context, blob *git.Blob, lastModified *time.Tim
                 log.Error("ServeBlobOrLFS: Close: %v", err)
             }
         }()
+        lfsServes.WithLabelValues("proxied").Inc()
+        if download_service.Setting().VerifyLFSDownloads {
+            // a compressed response has no Content-Length, the client could not tell an aborted download
+            ctx.Resp.Header().Set(gzhttp.HeaderNoCompression, "1")
+            common.ServeContentByReadSeeker(ctx.Base, ctx.Repo.TreePath, lastModified, newLFSVerifyingReader(lfsDataRc, meta.Pointer, ctx.Repo.Repository.FullName()))
+            return nil
+        }
         common.ServeContentByReadSeeker(ctx.Base, ctx.Repo.TreePath, lastModified, lfsDataRc)
         return nil
     }
@@ -85,10 +109,13 @@ func ServeBlobOrLFS(ctx *context.Context, blob *git.Blob, lastModified *time.Tim
     }
     closed = true
 
+    setBlobDigestHeaders(ctx, blob)
     return common.ServeBlob(ctx.Base, ctx.Repo.Repository, ctx.Repo.TreePath, blob, lastModified)
 }
 
-func getBlobForEntry(ctx *context.Context) (*git.Blob, *time.Time) {
+// getBlobForEntry returns the blob of the requested path. Directories are listed instead, with links to the
+// same handler ("raw" or "media"), and nil is returned like for every other written response.
+func getBlobForEntry(ctx *context.Context, obs *downloadObservation, handler string) (*git.Blob, *time.Time) {
     entry, err := ctx.Repo.Commit.GetTreeEntryByPath(ctx.Repo.TreePath)
     if err != nil {
         if git.IsErrNotExist(err) {
@@ -99,7 +126,12 @@ func getBlobForEntry(ctx *context.Context) (*git.Blob, *time.Time) {
         return nil, nil
     }
 
-    if entry.IsDir() || entry.IsSubModule() {
+    if entry.IsDir() {
+        obs.setPage()
+        serveRawDirectory(ctx, entry, handler)
+        return nil, nil
+    }
+    if entry.IsSubModule() {
         ctx.NotFound(nil)
         return nil, nil
     }
@@ -116,22 +148,34 @@ func getBlobForEntry(ctx *context.Context) (*git.Blob, *time.Time) {
 
 // SingleDownload download a file by repos path
 func SingleDownload(ctx *context.Context) {
-    blob, lastModified := getBlobForEntry(ctx)
+    obs := observeDownload(ctx, "raw", "file")
+    defer obs.done()
+
+    blob, lastModified := getBlobForEntry(ctx, obs, "raw")
     if blob == nil {
         return
     }
+    if !throttleDownload(ctx, downloadKindRaw) {
+        return
+    }
 
-    if err := common.ServeBlob(ctx.Base, ctx.Repo.Repository, ctx.Repo.TreePath, blob, lastModified); err != nil {
+    if err := serveRawBlob(ctx, blob, lastModified); err != nil {
         ctx.ServerError("ServeBlob", err)
     }
 }
 
 // SingleDownloadOrLFS download a file by repos path redirecting to LFS if necessary
 func SingleDownloadOrLFS(ctx *context.Context) {
-    blob, lastModified := getBlobForEntry(ctx)
+    obs := observeDownload(ctx, "media", "file")
+    defer obs.done()
+
+    blob, lastModified := getBlobForEntry(ctx, obs, "media")
     if blob == nil {
         return
     }
+    if !throttleDownload(ctx, downloadKindRaw) {
+        return
+    }
 
     if err := ServeBlobOrLFS(ctx, blob, lastModified); err != nil {
         ctx.ServerError("ServeBlobOrLFS", err)
@@ -140,6 +184,11 @@ func SingleDownloadOrLFS(ctx *context.Context) {
 
 // DownloadByID download a file by sha1 ID
 func DownloadByID(ctx *context.Context) {
+    obs := observeDownload(ctx, "raw_blob", "file")
+    defer obs.done()
+
+    obs.setTarget(ctx.PathParam("sha"), "", "")
+
     blob, err := ctx.Repo.GitRepo.GetBlob(ctx.PathParam("sha"))
     if err != nil {
         if git.IsErrNotExist(err) {
@@ -149,13 +198,21 @@ func DownloadByID(ctx *context.Context) {
         }
         return
     }
-    if err = common.ServeBlob(ctx.Base, ctx.Repo.Repository, ctx.Repo.TreePath, blob, nil); err != nil {
+    if !throttleDownload(ctx, downloadKindRaw) {
+        return
+    }
+    if err = serveRawBlob(ctx, blob, nil); err != nil {
         ctx.ServerError("ServeBlob", err)
     }
 }
 
 // DownloadByIDOrLFS download a file by sha1 ID taking account of LFS
 func DownloadByIDOrLFS(ctx *context.Context) {
+    obs := observeDownload(ctx, "media_blob", "file")
+    defer obs.done()
+
+    obs.setTarget(ctx.PathParam("sha"), "", "")
+
     blob, err := ctx.Repo.GitRepo.GetBlob(ctx.PathParam("sha"))
     if err != nil {
         if git.IsErrNotExist(err) {
@@ -165,6 +222,9 @@ func DownloadByIDOrLFS(ctx *context.Context) {
         }
         return
     }
+    if !throttleDownload(ctx, downloadKindRaw) {
+        return
+    }
     if err = ServeBlobOrLFS(ctx, blob, nil); err != nil {
         ctx.ServerError("ServeBlob", err)
     }
@@ -174,67 +234,193 @@ func DownloadByIDOrLFS(ctx *context.Context) {
 func DownloadFolder(ctx *context.Context) {
 	// Get path from route parameter
 	treePath := ctx.PathParam("*")
-	
-	// Get branch name from route parameter (if present)
-	branchName := ctx.PathParam("branchname")
-	
+
 	// Get format from query parameter
 	format := ctx.Req.URL.Query().Get("format")
 	if format == "" {
 		format = "zip" // default
 	}
-	
+
+	// "{folder}/manifest" and "{folder}/summary" describe the folder instead of archiving it
+	if format == "json" || format == "csv" {
+		obs := observeDownload(ctx, "folder", format)
+		defer obs.done()
+		if !serveFolderDescription(ctx, obs, treePath, format) {
+			ctx.HTTPError(http.StatusBadRequest, fmt.Sprintf("format '%s' is only supported for the manifest and summary of a folder", format))
+		}
+		return
+	}
+	if !isFolderArchiveFormat(format) {
+		ctx.HTTPError(http.StatusBadRequest, fmt.Sprintf("unsupported archive format '%s'", format))
+		return
+	}
+	obs := observeDownload(ctx, "folder", download_service.ArchiveExt(format))
+	defer obs.done()
+
+	opts, err := parseFolderArchiveOptions(ctx)
+	if err != nil {
+		ctx.HTTPError(http.StatusBadRequest, err.Error())
+		return
+	}
+	partSize, err := download_service.ParseSplitPartSize(ctx.FormString("split"))
+	if err != nil {
+		ctx.HTTPError(http.StatusBadRequest, err.Error())
+		return
+	}
+	if partSize > 0 && format == download_service.ArchiveFormatZipAES {
+		ctx.HTTPError(http.StatusBadRequest, "encrypted zip archives cannot be split")
+		return
+	}
+	signature, verifyPage := ctx.FormBool("signature"), ctx.FormBool("verify")
+	if (signature || verifyPage) && (partSize > 0 || format == download_service.ArchiveFormatZipAES) {
+		ctx.HTTPError(http.StatusBadRequest, "only plain folder archives are signed")
+		return
+	}
+
+	commit, decodedPath := getFolderForDownload(ctx, obs, treePath)
+	if commit == nil {
+		return
+	}
+	if tree, err := download_service.GetTree(commit, decodedPath); err == nil {
+		setObjectIDHeader(ctx.Resp, tree.ID)
+	}
+
+	// Set download headers
+	folderName := path.Base(decodedPath)
+	if folderName == "" || folderName == "." || folderName == "/" {
+		folderName = ctx.Repo.Repository.Name
+	}
+
+	if !checkArchivePolicy(ctx, true) {
+		return
+	}
+	baseName := fmt.Sprintf("%s-%s", folderName, commit.ID.String()[:7])
+	// split archives are generated into the archive cache and listed on a page, each part is downloaded separately
+	if partSize > 0 {
+		// the page is not a download, the parts are audited when they are fetched
+		obs.setPage()
+		serveSplitFolderArchive(ctx, commit, decodedPath, baseName, format, opts, partSize)
+		return
+	}
+	if signature || verifyPage {
+		obs.setPage()
+		serveFolderArchiveSignature(ctx, commit, decodedPath, baseName, format, opts, verifyPage)
+		return
+	}
+	if format == download_service.ArchiveFormatZipAES {
+		var ok bool
+		if opts.Password, ok = resolveZipAESPassword(ctx); !ok {
+			obs.setPage()
+			return
+		}
+	}
+	if !throttleDownload(ctx, downloadKindArchive) {
+		return
+	}
+	// archives built ahead of time, for example by a pre-build on push, are served from the archive cache
+	if format != download_service.ArchiveFormatZipAES {
+		a, err := download_service.ReadCachedArchive(ctx.Repo.Repository.ID, download_service.ArchiveCacheKey(commit.ID.String(), decodedPath, format, opts, 0))
+		if err == nil && len(a.Parts) == 1 {
+			download_service.ObserveCacheLookup("folder_archive", true)
+			serveCachedFolderArchive(ctx, a)
+			return
+		}
+	}
+	// when the archive storage can hand out signed URLs, the archive is built into the cache and downloaded from
+	// the storage instead of through this process
+	if format != download_service.ArchiveFormatZipAES && setting.RepoArchive.Storage.ServeDirect() {
+		release := startArchiveJob(ctx)
+		if release == nil {
+			return
+		}
+		a, err := download_service.GetOrCreateCachedArchive(ctx, ctx.Repo.Repository, ctx.Repo.GitRepo, commit, decodedPath, baseName, format, opts, 0)
+		release()
+		if errors.Is(err, download_service.ErrArchiveTooLarge) {
+			ctx.HTTPError(http.StatusUnprocessableEntity, err.Error())
+			return
+		} else if err != nil {
+			ctx.ServerError("GetOrCreateCachedArchive", err)
+			return
+		}
+		serveCachedFolderArchive(ctx, a)
+		return
+	}
+	release := startArchiveJob(ctx)
+	if release == nil {
+		return
+	}
+	defer release()
+
+	setFolderArchiveHeaders(ctx, baseName, format)
+
+	// Используем оптимизированную версию с буферизацией для больших архивов
+	done := download_service.ObserveArchiveGeneration(download_service.ArchiveExt(format))
+	err = download_service.CreateArchive(ctx.Resp, ctx.Repo.GitRepo, commit, decodedPath, format, opts)
+	done()
+	if err != nil {
+		ctx.ServerError("CreateArchive", err)
+		return
+	}
+}
+
+// DownloadFolderPost handles the forms posted to a folder download: the captcha of
+// anonymous requests and the password of "zip-aes" archives
+func DownloadFolderPost(ctx *context.Context) {
+	if ctx.FormString("captcha_id") != "" {
+		DownloadArchiveCaptchaPost(ctx)
+		return
+	}
+	DownloadFolder(ctx)
+}
+
+// getFolderForDownload resolves the commit and the normalized folder path of a folder download and
+// sets them as the target of obs. It returns a nil commit if the response has already been written.
+func getFolderForDownload(ctx *context.Context, obs *downloadObservation, treePath string) (*git.Commit, string) {
+	// Get branch name from route parameter (if present)
+	branchName := ctx.PathParam("branchname")
+
 	// Если путь не указан, используем текущий путь из контекста
 	if treePath == "" && ctx.Repo.TreePath != "" {
 		treePath = ctx.Repo.TreePath
 	}
-	
+
 	// URL decode the path
 	decodedPath, err := url.PathUnescape(treePath)
 	if err != nil {
 		decodedPath = treePath
 	}
-	
-	// Remove leading slash if present
-	decodedPath = strings.TrimPrefix(decodedPath, "/")
-	
-	// Если путь ".", это вся репа, оставляем как есть (git archive обработает)
-	if decodedPath == "." {
-		decodedPath = ""
+
+	// Normalize the path, an empty result means the whole repository
+	decodedPath, err = download_service.CleanTreePath(decodedPath)
+	if err != nil {
+		ctx.NotFound(err)
+		return nil, ""
 	}
-	
+
 	// Validate repository access
 	if ctx.Repo.Repository == nil || ctx.Repo.GitRepo == nil {
 		ctx.NotFound(fmt.Errorf("repository not found"))
-		return
+		return nil, ""
 	}
-	
-	// Determine which branch to use
-	var targetBranch string
-	if branchName != "" {
-		// Use branch from URL
-		targetBranch = branchName
-	} else if ctx.Repo.Commit != nil {
-		// Commit exists in context, use default branch
-		targetBranch = ctx.Repo.Repository.DefaultBranch
-		if targetBranch == "" {
-			targetBranch = "main"
-		}
-	} else {
-		// No branch specified, use default
+
+	// Use the branch from the URL, fall back to the default branch
+	targetBranch := branchName
+	if targetBranch == "" {
 		targetBranch = ctx.Repo.Repository.DefaultBranch
 		if targetBranch == "" {
 			targetBranch = "main"
 		}
 	}
-	
+
 	// Get commit for the branch
 	commit, err := ctx.Repo.GitRepo.GetCommit(targetBranch)
 	if err != nil {
 		ctx.ServerError("GetCommit", err)
-		return
+		return nil, ""
 	}
-	
+
+	obs.setTarget(targetBranch, commit.ID.String(), decodedPath)
+
 	// Verify path exists and is a directory (если путь указан)
 	if decodedPath != "" {
 		_, err := commit.SubTree(decodedPath)
@@ -244,104 +430,91 @@ func DownloadFolder(ctx *context.Context) {
 			} else {
 				ctx.ServerError("CheckDirectory", err)
 			}
-			return
+			return nil, ""
 		}
 	}
-	
-	// Set download headers
-	folderName := path.Base(decodedPath)
-	if folderName == "" || folderName == "." || folderName == "/" {
-		folderName = ctx.Repo.Repository.Name
+	return commit, decodedPath
+}
+
+// DownloadTreeByID download a tree object by sha1 ID as archive in specified format
+func DownloadTreeByID(ctx *context.Context) {
+	format := ctx.FormString("format")
+	if format != "" && (!isFolderArchiveFormat(format) || format == download_service.ArchiveFormatZipAES) {
+		ctx.HTTPError(http.StatusBadRequest, fmt.Sprintf("unsupported archive format '%s'", format))
+		return
 	}
-	
-	// Determine file extension based on format
-	var fileExt string
-	switch strings.ToLower(format) {
-	case "tar":
-		fileExt = "tar"
-	case "tar.gz", "tgz", "gz":
-		fileExt = "tar.gz"
-	default: // zip
-		fileExt = "zip"
-	}
-	
-	archiveName := fmt.Sprintf("%s-%s.%s", folderName, commit.ID.String()[:7], fileExt)
-	
-	// Set Content-Type based on format
-	switch strings.ToLower(format) {
-	case "tar":
-		ctx.Resp.Header().Set("Content-Type", "application/x-tar")
-	case "tar.gz", "tgz", "gz":
-		ctx.Resp.Header().Set("Content-Type", "application/gzip")
-	default: // zip
-		ctx.Resp.Header().Set("Content-Type", "application/zip")
-	}
-	
-	ctx.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, archiveName))
-	
-	// Используем оптимизированную версию с буферизацией для больших архивов
-	err = createGitArchiveBuffered(ctx.Resp, ctx.Repo.GitRepo.Path, commit.ID.String(), decodedPath, format)
+	obs := observeDownload(ctx, "tree", download_service.ArchiveExt(format))
+	defer obs.done()
+
+	treeID := ctx.PathParam("sha")
+	tree, err := ctx.Repo.GitRepo.GetTree(treeID)
 	if err != nil {
-		ctx.ServerError("CreateArchive", err)
+		if git.IsErrNotExist(err) {
+			ctx.NotFound(nil)
+		} else {
+			ctx.ServerError("GetTree", err)
+		}
 		return
 	}
-}
+	// GetTree also accepts commit IDs, only serve real tree objects here
+	if tree.ID.String() != treeID {
+		ctx.NotFound(nil)
+		return
+	}
+	obs.setTarget(treeID, "", "")
 
-// createGitArchiveBuffered создает архив через git archive с буферизацией для больших файлов
-func createGitArchiveBuffered(w io.Writer, repoPath string, commitHash string, treePath string, format string) error {
-	// Проверяем доступность git
-	if _, err := exec.LookPath("git"); err != nil {
-		return fmt.Errorf("git not found: %v", err)
+	if httpcache.HandleGenericETagCache(ctx.Req, ctx.Resp, `"`+treeID+"."+download_service.ArchiveExt(format)+`"`) {
+		return
 	}
-	
-	// Определяем формат
-	var formatArg string
+
+	if !checkArchivePolicy(ctx, true) {
+		return
+	}
+	if !throttleDownload(ctx, downloadKindArchive) {
+		return
+	}
+	release := startArchiveJob(ctx)
+	if release == nil {
+		return
+	}
+	defer release()
+
+	// tree IDs are content-addressed, so the archive never changes
+	ctx.Resp.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	setFolderArchiveHeaders(ctx, fmt.Sprintf("%s-%s", ctx.Repo.Repository.Name, treeID[:7]), format)
+
+	done := download_service.ObserveArchiveGeneration(download_service.ArchiveExt(format))
+	err = download_service.CreateGitArchive(ctx.Resp, ctx.Repo.GitRepo.Path, treeID, "", format)
+	done()
+	if err != nil {
+		ctx.ServerError("CreateArchive", err)
+	}
+}
+
+// isFolderArchiveFormat reports whether format is one of the archive formats of folder downloads
+func isFolderArchiveFormat(format string) bool {
 	switch strings.ToLower(format) {
+	case "zip", "tar", "tar.gz", "tgz", "gz", download_service.ArchiveFormatZipAES:
+		return true
+	}
+	return false
+}
+
+// setFolderArchiveHeaders sets Content-Type and Content-Disposition for an archive named baseName
+func setFolderArchiveHeaders(ctx *context.Context, baseName, format string) {
+	fileExt := download_service.ArchiveExt(format)
+	ctx.Resp.Header().Set("Content-Type", folderArchiveContentType(fileExt))
+	ctx.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, baseName, fileExt))
+}
+
+// folderArchiveContentType returns the content type of an archive with the extension fileExt
+func folderArchiveContentType(fileExt string) string {
+	switch fileExt {
 	case "tar":
-		formatArg = "tar"
-	case "tar.gz", "tgz", "gz":
-		formatArg = "tar.gz"
+		return "application/x-tar"
+	case "tar.gz":
+		return "application/gzip"
 	default:
-		formatArg = "zip"
-	}
-	
-	// Создаем команду
-	args := []string{"archive", "--format=" + formatArg, commitHash}
-	if treePath != "" && treePath != "." {
-		args = append(args, strings.TrimPrefix(treePath, "/"))
-	}
-	
-	cmd := exec.Command("git", args...)
-	cmd.Dir = repoPath
-	
-	// Получаем stdout команды
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %v", err)
-	}
-	
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	
-	// Запускаем команду
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start git: %v", err)
-	}
-	
-	// Копируем данные с буферизацией
-	_, copyErr := io.Copy(w, stdout)
-	
-	// Ждем завершения команды
-	waitErr := cmd.Wait()
-	
-	// Обрабатываем ошибки
-	if copyErr != nil {
-		return fmt.Errorf("failed to copy archive data: %v", copyErr)
-	}
-	
-	if waitErr != nil {
-		return fmt.Errorf("git archive failed: %v\nstderr: %s", waitErr, stderr.String())
-	}
-	
-	return nil
-}
\ No newline at end of file
+		return "application/zip"
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// parseFolderArchiveOptions reads the archive options from the request query
+func parseFolderArchiveOptions(ctx *context.Context) (*download_service.ArchiveOptions, error) {
+	opts := &download_service.ArchiveOptions{
+		Symlinks: ctx.FormString("symlinks"),
+		Mtime:    ctx.FormString("mtime"),
+	}
+	switch opts.Symlinks {
+	case "":
+		opts.Symlinks = download_service.ArchiveSymlinksKeep
+	case download_service.ArchiveSymlinksKeep, download_service.ArchiveSymlinksFollow, download_service.ArchiveSymlinksSkip:
+	default:
+		return nil, fmt.Errorf("invalid symlinks mode '%s'", opts.Symlinks)
+	}
+
+	switch eol := ctx.FormString("eol"); eol {
+	case "", download_service.ArchiveEOLLF, download_service.ArchiveEOLCRLF:
+		opts.EOL = eol
+	case "native":
+		// use the line endings "git checkout" produces on the client's platform
+		opts.EOL = download_service.ArchiveEOLLF
+		if strings.Contains(ctx.Req.UserAgent(), "Windows") {
+			opts.EOL = download_service.ArchiveEOLCRLF
+		}
+	default:
+		return nil, fmt.Errorf("invalid eol '%s'", eol)
+	}
+
+	switch opts.Mtime {
+	case "":
+		opts.Mtime = download_service.ArchiveMtimeCommit
+	case download_service.ArchiveMtimeCommit, download_service.ArchiveMtimeFile:
+	default:
+		return nil, fmt.Errorf("invalid mtime '%s'", opts.Mtime)
+	}
+	return opts, nil
+}
@@ -0,0 +1,309 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const (
+	tplAdminDownloadAudit    templates.TplName = "admin/download_audit"
+	tplSettingsDownloadAudit templates.TplName = "repo/download_audit"
+
+	downloadAuditDayLayout = "2006-01-02"
+	downloadAuditMaxExport = 100000
+)
+
+// DownloadAuditRecord is one audited download as it is shown and exported
+type DownloadAuditRecord struct {
+	Time    time.Time `json:"time"`
+	UserID  int64     `json:"user_id"`
+	User    string    `json:"user"`
+	IP      string    `json:"ip"`
+	Repo    string    `json:"repo"`
+	Handler string    `json:"handler"`
+	Ref     string    `json:"ref"`
+	Commit  string    `json:"commit"`
+	Path    string    `json:"path"`
+	Format  string    `json:"format"`
+	Bytes   int64     `json:"bytes"`
+	Status  int       `json:"status"`
+}
+
+// downloadAuditTarget is what a handler resolved the request to, see downloadObservation.setTarget
+type downloadAuditTarget struct {
+	Ref    string
+	Commit string
+	Path   string
+}
+
+func toDownloadAuditRecord(audit *repo_model.DownloadAudit) *DownloadAuditRecord {
+	return &DownloadAuditRecord{
+		Time:    audit.CreatedUnix.AsTime().UTC(),
+		UserID:  audit.UserID,
+		User:    audit.UserName,
+		IP:      audit.IP,
+		Repo:    audit.RepoName,
+		Handler: audit.Handler,
+		Ref:     audit.Ref,
+		Commit:  audit.CommitID,
+		Path:    audit.Path,
+		Format:  audit.Format,
+		Bytes:   audit.Bytes,
+		Status:  audit.Status,
+	}
+}
+
+// recordDownloadAudit writes the audit record of a finished download on a private repository,
+// a nil target is taken from the repository context
+func recordDownloadAudit(ctx *context.Context, handler, format string, target *downloadAuditTarget) {
+	s := download_service.Setting()
+	if !s.AuditLogEnabled || ctx.Repo.Repository == nil || (!ctx.Repo.Repository.IsPrivate && !s.AuditPublicRepositories) {
+		return
+	}
+
+	audit := &repo_model.DownloadAudit{
+		RepoID:      ctx.Repo.Repository.ID,
+		RepoName:    ctx.Repo.Repository.FullName(),
+		IP:          archiveRequestIP(ctx),
+		Handler:     handler,
+		Format:      format,
+		Bytes:       int64(ctx.Resp.WrittenSize()),
+		Status:      ctx.Resp.WrittenStatus(),
+		CreatedUnix: timeutil.TimeStampNow(),
+	}
+	if audit.Status == 0 {
+		audit.Status = http.StatusOK
+	}
+	if ctx.Doer != nil {
+		audit.UserID = ctx.Doer.ID
+		audit.UserName = ctx.Doer.Name
+	}
+	if target != nil {
+		audit.Ref, audit.CommitID, audit.Path = target.Ref, target.Commit, target.Path
+	} else {
+		audit.Ref = ctx.Repo.RefFullName.ShortName()
+		if ctx.Repo.Commit != nil {
+			audit.CommitID = ctx.Repo.Commit.ID.String()
+		}
+		audit.Path = ctx.Repo.TreePath
+	}
+
+	if err := repo_model.InsertDownloadAudit(ctx, audit); err != nil {
+		log.Error("InsertDownloadAudit: %v", err)
+	}
+}
+
+// ObserveRepoDownload audits and notifies downloads which are served outside this package:
+// "/archive/*" repository archives and release assets
+func ObserveRepoDownload(handler string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req)
+			ctx := context.GetWebContext(req.Context())
+			if ctx == nil {
+				return
+			}
+			var format string
+			var target *downloadAuditTarget
+			switch handler {
+			case "archive":
+				archiveName := ctx.PathParam("*")
+				format = strings.TrimPrefix(path.Ext(archiveName), ".")
+				if strings.HasSuffix(archiveName, ".tar.gz") {
+					format = "tar.gz"
+				}
+				target = &downloadAuditTarget{Ref: strings.TrimSuffix(archiveName, "."+format)}
+				// the archived commit, resolved like the archiver does, a branch may have moved on since
+				if ctx.Repo.GitRepo != nil && ctx.Resp.WrittenStatus() < http.StatusBadRequest {
+					if commitID, err := ctx.Repo.GitRepo.ConvertToGitID(target.Ref); err == nil {
+						target.Commit = commitID.String()
+					}
+				}
+			case "release":
+				format = "file"
+				if uuid := ctx.PathParam("uuid"); uuid != "" {
+					target = &downloadAuditTarget{Path: uuid}
+				} else {
+					target = &downloadAuditTarget{Ref: ctx.PathParam("vTag"), Path: ctx.PathParam("fileName")}
+				}
+			}
+			recordDownloadAudit(ctx, handler, format, target)
+			notifyRepositoryDownload(ctx, handler, format, target)
+		})
+	}
+}
+
+// downloadAuditFilter selects audit records, empty fields match everything
+type downloadAuditFilter struct {
+	Repo  string
+	User  string
+	Since string
+	Until string
+}
+
+// findOptions turns the filter into the options of a query, the days are already validated
+func (f *downloadAuditFilter) findOptions() repo_model.FindDownloadAuditOptions {
+	opts := repo_model.FindDownloadAuditOptions{RepoName: f.Repo, UserName: f.User}
+	if since, err := time.Parse(downloadAuditDayLayout, f.Since); err == nil {
+		opts.Since = timeutil.TimeStamp(since.Unix())
+	}
+	if until, err := time.Parse(downloadAuditDayLayout, f.Until); err == nil {
+		opts.Until = timeutil.TimeStamp(until.Add(24 * time.Hour).Unix())
+	}
+	return opts
+}
+
+// DownloadAuditEnabled reports whether downloads are recorded in the download audit log
+func DownloadAuditEnabled() bool {
+	return download_service.Setting().AuditLogEnabled
+}
+
+// DownloadAuditExport shows the download audit log, newest first, or exports it as JSON or CSV with
+// "format=json" or "format=csv". In the repository settings it is limited to the current repository,
+// in the admin panel the "repo" filter selects a repository. Both accept "user", "since" and "until"
+// (YYYY-MM-DD, UTC) filters.
+func DownloadAuditExport(ctx *context.Context) {
+	if !download_service.Setting().AuditLogEnabled {
+		ctx.NotFound(nil)
+		return
+	}
+
+	filter := &downloadAuditFilter{
+		Repo:  ctx.FormString("repo"),
+		User:  ctx.FormString("user"),
+		Since: ctx.FormString("since"),
+		Until: ctx.FormString("until"),
+	}
+	if ctx.Repo.Repository != nil {
+		filter.Repo = ""
+	}
+	format := ctx.FormString("format")
+	for _, day := range []string{filter.Since, filter.Until} {
+		if _, err := time.Parse(downloadAuditDayLayout, day); day != "" && err != nil {
+			if format == "" {
+				ctx.Flash.Error(ctx.Tr("repo.settings.download_audit_invalid_day", day), true)
+				filter.Since, filter.Until = "", ""
+				break
+			}
+			ctx.HTTPError(http.StatusBadRequest, fmt.Sprintf("invalid date '%s'", day))
+			return
+		}
+	}
+
+	opts := filter.findOptions()
+	if ctx.Repo.Repository != nil {
+		opts.RepoID = ctx.Repo.Repository.ID
+	}
+	if format == "" {
+		renderDownloadAudit(ctx, filter, opts)
+		return
+	}
+
+	// the export holds the newest records, oldest first
+	opts.ListOptions = db.ListOptions{Page: 1, PageSize: downloadAuditMaxExport}
+	audits, err := db.Find[repo_model.DownloadAudit](ctx, opts)
+	if err != nil {
+		ctx.ServerError("FindDownloadAudits", err)
+		return
+	}
+	slices.Reverse(audits)
+	records := make([]*DownloadAuditRecord, 0, len(audits))
+	for _, audit := range audits {
+		records = append(records, toDownloadAuditRecord(audit))
+	}
+
+	switch format {
+	case "json":
+		ctx.SetTotalCountHeader(int64(len(records)))
+		ctx.JSON(http.StatusOK, records)
+		return
+	case "csv":
+	default:
+		ctx.HTTPError(http.StatusBadRequest, fmt.Sprintf("invalid format '%s'", format))
+		return
+	}
+
+	ctx.SetTotalCountHeader(int64(len(records)))
+
+	ctx.Resp.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	ctx.Resp.Header().Set("Content-Disposition", `attachment; filename="download-audit.csv"`)
+	w := csv.NewWriter(ctx.Resp)
+	_ = w.Write([]string{"time", "user_id", "user", "ip", "repo", "handler", "ref", "commit", "path", "format", "bytes", "status"})
+	for _, r := range records {
+		_ = w.Write([]string{
+			r.Time.Format(time.RFC3339),
+			strconv.FormatInt(r.UserID, 10),
+			r.User,
+			r.IP,
+			r.Repo,
+			r.Handler,
+			r.Ref,
+			r.Commit,
+			r.Path,
+			r.Format,
+			strconv.FormatInt(r.Bytes, 10),
+			strconv.Itoa(r.Status),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Error("DownloadAuditExport: %v", err)
+	}
+}
+
+// renderDownloadAudit shows a page of the audit records, newest first
+func renderDownloadAudit(ctx *context.Context, filter *downloadAuditFilter, opts repo_model.FindDownloadAuditOptions) {
+	ctx.Data["Title"] = ctx.Tr("repo.settings.download_audit")
+	tpl := tplAdminDownloadAudit
+	ctx.Data["AuditLink"] = setting.AppSubURL + "/-/admin/download_audit"
+	if ctx.Repo.Repository != nil {
+		tpl = tplSettingsDownloadAudit
+		ctx.Data["PageIsSettingsDownloadAudit"] = true
+		ctx.Data["AuditLink"] = ctx.Repo.RepoLink + "/settings/download_audit"
+	} else {
+		ctx.Data["PageIsAdminDownloadAudit"] = true
+	}
+
+	page := max(ctx.FormInt("page"), 1)
+	pageSize := setting.UI.Admin.NoticePagingNum
+	opts.ListOptions = db.ListOptions{Page: page, PageSize: pageSize}
+	audits, total, err := db.FindAndCount[repo_model.DownloadAudit](ctx, opts)
+	if err != nil {
+		ctx.ServerError("FindDownloadAudits", err)
+		return
+	}
+	records := make([]*DownloadAuditRecord, 0, len(audits))
+	for _, audit := range audits {
+		records = append(records, toDownloadAuditRecord(audit))
+	}
+
+	ctx.Data["Filter"] = filter
+	ctx.Data["Records"] = records
+	ctx.Data["Total"] = total
+	ctx.Data["Truncated"] = total > downloadAuditMaxExport
+	ctx.Data["MaxExport"] = downloadAuditMaxExport
+	ctx.Data["ExportQuery"] = url.Values{"repo": {filter.Repo}, "user": {filter.User}, "since": {filter.Since}, "until": {filter.Until}}.Encode()
+	pager := context.NewPagination(int(total), pageSize, page, 5)
+	pager.AddParamFromRequest(ctx.Req)
+	ctx.Data["Page"] = pager
+	ctx.HTML(http.StatusOK, tpl)
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/storage"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/routers/common"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplDownloadParts templates.TplName = "repo/download_parts"
+
+// folderArchiveChecksumsName lists the checksums of the parts of a split archive in the sha256sum format
+const folderArchiveChecksumsName = "SHA256SUMS"
+
+// serveSplitFolderArchive renders the page listing the parts of a split folder archive with their checksums.
+// Until the archive is in the archive cache, its build is queued and the page polls the build status.
+func serveSplitFolderArchive(ctx *context.Context, commit *git.Commit, treePath, baseName, format string, opts *download_service.ArchiveOptions, partSize int64) {
+	key := download_service.ArchiveCacheKey(commit.ID.String(), treePath, format, opts, partSize)
+	ctx.Data["Title"] = ctx.Tr("repo.download_folder_parts_title")
+	ctx.Data["PartSize"] = partSize
+	ctx.Data["PartsLink"] = ctx.Repo.RepoLink + "/download/folder-part/" + key
+	ctx.Data["ChecksumsName"] = folderArchiveChecksumsName
+
+	a, err := download_service.ReadCachedArchive(ctx.Repo.Repository.ID, key)
+	if err == nil {
+		download_service.ObserveCacheLookup("folder_archive", true)
+		ctx.Data["Archive"] = a
+		ctx.HTML(http.StatusOK, tplDownloadParts)
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		ctx.ServerError("ReadCachedArchive", err)
+		return
+	}
+
+	status, ok := queueFolderArchiveSplit(ctx, &download_service.SplitTask{
+		RepoID:   ctx.Repo.Repository.ID,
+		Key:      key,
+		Commit:   commit.ID.String(),
+		Path:     treePath,
+		BaseName: baseName,
+		Format:   format,
+		Symlinks: opts.Symlinks,
+		EOL:      opts.EOL,
+		Mtime:    opts.Mtime,
+		PartSize: partSize,
+	}, ctx.FormBool("retry"))
+	if !ok {
+		return
+	}
+	if !ctx.Repo.IsAdmin() {
+		status.Error = ""
+	}
+	retryLink := ctx.Req.URL.Query()
+	retryLink.Set("retry", "true")
+	ctx.Data["SplitStatus"] = status
+	ctx.Data["SplitStatusLink"] = ctx.Repo.RepoLink + "/download/folder-status/" + key
+	ctx.Data["SplitRetryLink"] = ctx.Req.URL.Path + "?" + retryLink.Encode()
+	ctx.HTML(http.StatusOK, tplDownloadParts)
+}
+
+// redirectToCachedFolderArchive redirects to a signed URL of a cached object of size bytes when the archive storage
+// supports it. The bytes are not served by this process, so they are charged against the daily quotas before the
+// redirect, and a download under a bandwidth cap is streamed instead.
+func redirectToCachedFolderArchive(ctx *context.Context, key, objectName, fileName, contentType string, size int64) bool {
+	if !setting.RepoArchive.Storage.ServeDirect() {
+		return false
+	}
+	u, err := storage.RepoArchives.URL(download_service.ArchiveCachePath(ctx.Repo.Repository.ID, key, objectName), fileName, ctx.Req.Method,
+		url.Values{"response-content-type": {contentType}})
+	if err != nil {
+		log.Warn("Unable to get the signed URL of folder archive %s: %v", key, err)
+		return false
+	}
+	if u == nil || !allowRedirectedDownload(ctx, size) {
+		return false
+	}
+	ctx.Redirect(u.String())
+	return true
+}
+
+// serveCachedFolderArchive serves a cached archive which was not split
+func serveCachedFolderArchive(ctx *context.Context, a *download_service.CachedArchive) {
+	if redirectToCachedFolderArchive(ctx, a.Key, a.Parts[0].Name, a.Name, folderArchiveContentType(a.Format), a.Size) {
+		return
+	}
+	obj, err := storage.RepoArchives.Open(download_service.ArchiveCachePath(ctx.Repo.Repository.ID, a.Key, a.Parts[0].Name))
+	if err != nil {
+		ctx.ServerError("Open", err)
+		return
+	}
+	defer obj.Close()
+	setFolderArchiveHeaders(ctx, strings.TrimSuffix(a.Name, "."+a.Format), a.Format)
+	setSHA256DigestHeaders(ctx.Resp, a.SHA256)
+	http.ServeContent(ctx.Resp, ctx.Req, a.Name, a.Created, obj)
+}
+
+// DownloadFolderPart serves one part of a cached split folder archive, or its SHA256SUMS file
+func DownloadFolderPart(ctx *context.Context) {
+	a, err := download_service.ReadCachedArchive(ctx.Repo.Repository.ID, ctx.PathParam("key"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			ctx.NotFound(err)
+		} else {
+			ctx.ServerError("ReadCachedArchive", err)
+		}
+		return
+	}
+	name := ctx.PathParam("name")
+	obs := observeDownload(ctx, "folder_part", a.Format)
+	defer obs.done()
+	obs.setTarget("", a.Commit, a.Path)
+
+	if name == folderArchiveChecksumsName {
+		var sums strings.Builder
+		for _, part := range a.Parts {
+			fmt.Fprintf(&sums, "%s  %s\n", part.SHA256, part.Name)
+		}
+		ctx.PlainText(http.StatusOK, sums.String())
+		return
+	}
+
+	idx := slices.IndexFunc(a.Parts, func(part *download_service.CachedArchivePart) bool { return part.Name == name })
+	if idx < 0 {
+		ctx.NotFound(fmt.Errorf("archive part '%s' not found", name))
+		return
+	}
+	if !checkArchivePolicy(ctx, true) || !throttleDownload(ctx, downloadKindArchive) {
+		return
+	}
+
+	if redirectToCachedFolderArchive(ctx, a.Key, name, name, "application/octet-stream", a.Parts[idx].Size) {
+		return
+	}
+	obj, err := storage.RepoArchives.Open(download_service.ArchiveCachePath(ctx.Repo.Repository.ID, a.Key, name))
+	if err != nil {
+		ctx.ServerError("Open", err)
+		return
+	}
+	defer obj.Close()
+	common.ServeContentByReadSeeker(ctx.Base, name, &a.Created, obj)
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// setObjectIDHeader sends the SHA of the git blob or tree being downloaded
+func setObjectIDHeader(w http.ResponseWriter, id git.ObjectID) {
+	w.Header().Set("X-Gitea-Object-Id", id.String())
+}
+
+// setSHA256DigestHeaders sends a hex SHA-256 as Repr-Digest (RFC 9530) and as the older Digest (RFC 3230)
+func setSHA256DigestHeaders(w http.ResponseWriter, hexSum string) {
+	sum, err := hex.DecodeString(hexSum)
+	if err != nil || len(sum) != sha256.Size {
+		return
+	}
+	b64 := base64.StdEncoding.EncodeToString(sum)
+	w.Header().Set("Repr-Digest", "sha-256=:"+b64+":")
+	w.Header().Set("Digest", "SHA-256="+b64)
+}
+
+// setBlobDigestHeaders sends the object id of a git blob and its SHA-256, which is computed once per blob and
+// then kept in the cache. Blobs above DigestMaxSize are not hashed before being served and get no digest.
+// Range requests only get a digest already in the cache, a few bytes should not cost a read of the whole blob.
+func setBlobDigestHeaders(ctx *context.Context, blob *git.Blob) {
+	setObjectIDHeader(ctx.Resp, blob.ID)
+	if maxSize := download_service.Setting().DigestMaxSize; maxSize >= 0 && blob.Size() > maxSize {
+		return
+	}
+	cacheKey := "blob_sha256_" + blob.ID.String()
+	if ctx.Req.Header.Get("Range") != "" {
+		if c := cache.GetCache(); c != nil {
+			if sum, ok := c.Get(cacheKey); ok {
+				setSHA256DigestHeaders(ctx.Resp, sum)
+			}
+		}
+		return
+	}
+	sum, err := cache.GetString(cacheKey, func() (string, error) {
+		return blobSHA256(blob)
+	})
+	if err != nil {
+		log.Error("blobSHA256(%s): %v", blob.ID, err)
+		return
+	}
+	setSHA256DigestHeaders(ctx.Resp, sum)
+}
+
+func blobSHA256(blob *git.Blob) (string, error) {
+	dataRc, err := blob.DataAsync()
+	if err != nil {
+		return "", err
+	}
+	defer dataRc.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, dataRc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"slices"
+	"strings"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// folderArchiveExportFormatField is the name of the settings form checkbox of format
+func folderArchiveExportFormatField(format string) string {
+	return "format_" + strings.ReplaceAll(format, ".", "_")
+}
+
+// setFolderArchiveExportData adds the export settings to the folder archive settings page
+func setFolderArchiveExportData(ctx *context.Context) error {
+	ctx.Data["ExportEnabled"] = download_service.Setting().ExportEnabled
+	if !download_service.Setting().ExportEnabled {
+		return nil
+	}
+	export, err := repo_model.GetArchiveExport(ctx, ctx.Repo.Repository.ID)
+	if err != nil {
+		return err
+	}
+	ctx.Data["Export"] = export
+	ctx.Data["ExportFolders"] = strings.Join(export.Folders, "\n")
+	ctx.Data["ExportTags"] = strings.Join(export.Tags, ", ")
+	var formats []map[string]any
+	for _, format := range download_service.ExportFormats {
+		formats = append(formats, map[string]any{
+			"Name":    format,
+			"Field":   folderArchiveExportFormatField(format),
+			"Checked": slices.Contains(export.Formats, format),
+		})
+	}
+	ctx.Data["ExportFormats"] = formats
+	ctx.Data["ExportBucket"] = download_service.Setting().ExportStorage.MinioConfig.Bucket
+	return nil
+}
+
+// folderArchiveExportPost saves the export settings posted from the folder archive settings page
+func folderArchiveExportPost(ctx *context.Context) {
+	if !download_service.Setting().ExportEnabled {
+		ctx.NotFound(nil)
+		return
+	}
+
+	var folders, tags []string
+	for _, folder := range strings.Split(ctx.FormString("folders"), "\n") {
+		folder = strings.TrimSpace(folder)
+		if folder == "" {
+			continue
+		}
+		treePath, err := download_service.CleanTreePath(folder)
+		if err != nil {
+			ctx.Flash.Error(ctx.Tr("repo.settings.folder_archives_export_invalid_folder", folder))
+			ctx.Redirect(ctx.Repo.RepoLink + "/settings/archives")
+			return
+		}
+		if treePath == "" {
+			treePath = "/"
+		}
+		folders = append(folders, treePath)
+	}
+	for _, tag := range strings.Split(ctx.FormString("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	var formats []string
+	for _, format := range download_service.ExportFormats {
+		if ctx.FormBool(folderArchiveExportFormatField(format)) {
+			formats = append(formats, format)
+		}
+	}
+
+	if err := download_service.UpdateExport(ctx, ctx.Repo.Repository.ID, func(export *repo_model.ArchiveExport) {
+		export.Enabled = ctx.FormBool("enabled")
+		export.Folders, export.Tags, export.Formats = folders, tags, formats
+	}); err != nil {
+		ctx.ServerError("UpdateExport", err)
+		return
+	}
+	ctx.Flash.Success(ctx.Tr("repo.settings.update_settings_success"))
+	ctx.Redirect(ctx.Repo.RepoLink + "/settings/archives")
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplLFSMissing templates.TplName = "repo/lfs_missing"
+
+// serveLFSMissing answers a download of an LFS pointer whose object is not on the server with
+// X-Gitea-LFS-Missing, a page explaining it to browsers and LFS_MISSING_STATUS to other clients
+func serveLFSMissing(ctx *context.Context, blob *git.Blob, pointer lfs.Pointer) {
+	lfsServes.WithLabelValues("missing").Inc()
+	header := ctx.Resp.Header()
+	// the headers describing the object do not apply and the response must not outlive an upload of the object
+	for _, name := range []string{"ETag", "Last-Modified", "Repr-Digest", "Digest"} {
+		header.Del(name)
+	}
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Gitea-LFS-Missing", pointer.Oid)
+
+	status := download_service.Setting().LFSMissingStatus
+	accept := ctx.Req.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/html"):
+		ctx.Data["Title"] = ctx.Tr("repo.lfs_missing_title")
+		ctx.Data["FileName"] = path.Base(ctx.Repo.TreePath)
+		ctx.Data["LFSPointer"] = pointer
+		ctx.Data["PointerLink"] = ctx.Repo.RepoLink + "/raw/blob/" + blob.ID.String()
+		if ctx.Repo.IsAdmin() {
+			ctx.Data["LFSMissingLink"] = ctx.Repo.RepoLink + "/settings/lfs/pointers?filter=missing"
+		}
+		ctx.HTML(status, tplLFSMissing)
+	case strings.Contains(accept, "application/json"):
+		ctx.JSON(status, map[string]any{
+			"message": "the LFS object of this file is not on the server",
+			"oid":     pointer.Oid,
+			"size":    pointer.Size,
+		})
+	default:
+		ctx.HTTPError(status, fmt.Sprintf("the LFS object %s of this file is not on the server", pointer.Oid))
+	}
+}
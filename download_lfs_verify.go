@@ -0,0 +1,115 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/cron"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const (
+	tplLFSStorageReport templates.TplName = "admin/lfs_storage"
+
+	// lfsStorageScanTaskName is the cron task running the LFS storage scan
+	lfsStorageScanTaskName = "lfs_storage_scan"
+)
+
+var errLFSChecksumMismatch = errors.New("LFS object does not match its OID")
+
+// lfsVerifyingReader checks the SHA-256 of an LFS object while it is served. A mismatch is only detected once
+// the object has been read entirely, so the last read is withheld and fails instead: the client gets a short
+// response rather than a complete corrupt file. Reads after a seek to anything but the start are not verified,
+// which is what happens for range requests.
+type lfsVerifyingReader struct {
+	io.ReadSeeker
+	pointer lfs.Pointer
+	repo    string
+
+	h        hash.Hash
+	read     int64
+	verify   bool
+	mismatch bool
+}
+
+func newLFSVerifyingReader(rs io.ReadSeeker, pointer lfs.Pointer, repo string) *lfsVerifyingReader {
+	return &lfsVerifyingReader{ReadSeeker: rs, pointer: pointer, repo: repo, h: sha256.New(), verify: true}
+}
+
+func (r *lfsVerifyingReader) Read(p []byte) (int, error) {
+	if r.mismatch {
+		return 0, errLFSChecksumMismatch
+	}
+	n, err := r.ReadSeeker.Read(p)
+	if !r.verify {
+		return n, err
+	}
+	r.h.Write(p[:n])
+	r.read += int64(n)
+	if r.read < r.pointer.Size && err == nil {
+		return n, nil
+	}
+	if r.read == r.pointer.Size && hex.EncodeToString(r.h.Sum(nil)) == r.pointer.Oid {
+		r.verify = false
+		return n, err
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, err
+	}
+	r.mismatch = true
+	log.Error("LFS object %s of %s is corrupt: %d bytes read for a size of %d or wrong SHA-256, the download has been aborted", r.pointer.Oid, r.repo, r.read, r.pointer.Size)
+	lfsServes.WithLabelValues("corrupt").Inc()
+	return 0, errLFSChecksumMismatch
+}
+
+func (r *lfsVerifyingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	// net/http seeks to the end to get the size and back to the start before serving the whole object
+	r.verify = pos == 0 && !r.mismatch
+	r.h.Reset()
+	r.read = 0
+	return pos, nil
+}
+
+// LFSStorageScan shows the result of the last LFS storage scan
+func LFSStorageScan(ctx *context.Context) {
+	ctx.Data["Title"] = ctx.Tr("admin.lfs_storage.title")
+	ctx.Data["PageIsAdminLFSStorage"] = true
+	ctx.Data["LFSStorageScanAvailable"] = cron.GetTask(lfsStorageScanTaskName) != nil
+
+	report, err := download_service.ReadLFSStorageReport()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		ctx.ServerError("ReadLFSStorageReport", err)
+		return
+	}
+	ctx.Data["Report"] = report
+	ctx.HTML(http.StatusOK, tplLFSStorageReport)
+}
+
+// LFSStorageScanPost starts an LFS storage scan in the background
+func LFSStorageScanPost(ctx *context.Context) {
+	task := cron.GetTask(lfsStorageScanTaskName)
+	if task == nil {
+		ctx.NotFound(nil)
+		return
+	}
+	go task.RunWithUser(ctx.Doer, nil)
+	ctx.Flash.Success(ctx.Tr("admin.lfs_storage.scan_started"))
+	ctx.Redirect(setting.AppSubURL + "/-/admin/lfs_storage")
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"code.gitea.io/gitea/modules/lfs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLFSVerifyingReader(t *testing.T) {
+	content := strings.Repeat("large file content\n", 1000)
+	sum := sha256.Sum256([]byte(content))
+	pointer := lfs.Pointer{Oid: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+	newReader := func(data string) *lfsVerifyingReader {
+		return newLFSVerifyingReader(strings.NewReader(data), pointer, "user2/repo1")
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		data, err := io.ReadAll(newReader(content))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+
+		data, err = io.ReadAll(iotest.OneByteReader(newReader(content)))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		corrupt := "L" + content[1:]
+		data, err := io.ReadAll(newReader(corrupt))
+		assert.ErrorIs(t, err, errLFSChecksumMismatch)
+		// the last read is withheld, the client never gets the complete corrupt file
+		assert.Less(t, len(data), len(corrupt))
+
+		r := newReader(corrupt)
+		_, _ = io.ReadAll(r)
+		_, err = r.Read(make([]byte, 10))
+		assert.ErrorIs(t, err, errLFSChecksumMismatch)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		_, err := io.ReadAll(newReader(content[:len(content)-1]))
+		assert.ErrorIs(t, err, errLFSChecksumMismatch)
+	})
+
+	t.Run("Longer", func(t *testing.T) {
+		_, err := io.ReadAll(newReader(content + "extra"))
+		assert.ErrorIs(t, err, errLFSChecksumMismatch)
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		// net/http seeks to the end for the size and back to the start before serving everything
+		r := newReader(content)
+		size, err := r.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		assert.Equal(t, pointer.Size, size)
+		_, err = r.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+
+		r = newReader("L" + content[1:])
+		_, err = r.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		_, err = r.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		_, err = io.ReadAll(iotest.OneByteReader(r))
+		assert.ErrorIs(t, err, errLFSChecksumMismatch)
+
+		// range requests are not verified
+		r = newReader("L" + content[1:])
+		_, err = r.Seek(100, io.SeekStart)
+		require.NoError(t, err)
+		data, err = io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, content[100:], string(data))
+	})
+}
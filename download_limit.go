@@ -0,0 +1,175 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// archiveLimiterIdleTTL is how long an unused token bucket is kept before it is pruned
+const archiveLimiterIdleTTL = 10 * time.Minute
+
+// archiveWorkers is the global pool of archive workers, nil if the pool is disabled
+var archiveWorkers = sync.OnceValue(func() chan struct{} {
+	if n := download_service.Setting().MaxConcurrentArchives; n > 0 {
+		return make(chan struct{}, n)
+	}
+	return nil
+})
+
+var archiveRateLimiters = sync.OnceValue(func() (limiters struct{ user, ip, anonymous *rateLimiter }) {
+	s := download_service.Setting()
+	limiters.user = newRateLimiter(s.UserRateLimit, s.UserRateBurst)
+	limiters.ip = newRateLimiter(s.IPRateLimit, s.IPRateBurst)
+	limiters.anonymous = newRateLimiter(s.AnonymousRateLimit, s.AnonymousRateBurst)
+	return limiters
+})
+
+// startArchiveJob applies the rate limits and waits for a free archive worker.
+// It returns nil if the request has been rejected, otherwise the returned function
+// must be called once the archive has been generated.
+func startArchiveJob(ctx *context.Context) func() {
+	if wait, ok := allowArchiveRequest(ctx); !ok {
+		archiveTooManyRequests(ctx, wait)
+		return nil
+	}
+
+	workers := archiveWorkers()
+	if workers == nil {
+		return func() {}
+	}
+	release := func() { <-workers }
+	select {
+	case workers <- struct{}{}:
+		return release
+	default:
+	}
+
+	timer := time.NewTimer(download_service.Setting().ArchiveQueueTimeout)
+	defer timer.Stop()
+	select {
+	case workers <- struct{}{}:
+		return release
+	case <-timer.C:
+		archiveTooManyRequests(ctx, download_service.Setting().ArchiveQueueTimeout)
+		return nil
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// allowArchiveRequest takes a token from the buckets of the requester,
+// signed-in users are limited per user and per IP, anonymous requests per IP only.
+func allowArchiveRequest(ctx *context.Context) (time.Duration, bool) {
+	limiters := archiveRateLimiters()
+	ip := archiveRequestIP(ctx)
+	if ctx.Doer == nil {
+		return takeTokens(rateLimiterKey{limiters.anonymous, ip})
+	}
+	return takeTokens(rateLimiterKey{limiters.user, strconv.FormatInt(ctx.Doer.ID, 10)}, rateLimiterKey{limiters.ip, ip})
+}
+
+func archiveRequestIP(ctx *context.Context) string {
+	addr := ctx.RemoteAddr()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func archiveTooManyRequests(ctx *context.Context, wait time.Duration) {
+	ctx.Resp.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+	ctx.HTTPError(http.StatusTooManyRequests, "too many archive requests, please retry later")
+}
+
+// rateLimiter is a set of token buckets keyed by user or IP
+type rateLimiter struct {
+	perSecond float64
+	burst     float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests with the given burst, nil if perMinute is not positive
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(max(burst, 1)),
+		buckets:   make(map[string]*tokenBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// rateLimiterKey is the bucket of key in a limiter
+type rateLimiterKey struct {
+	limiter *rateLimiter
+	key     string
+}
+
+// takeTokens takes a token from every bucket only if none of them is empty, so a request rejected by one
+// limit is not charged to the others. Otherwise it returns how long to wait until all of them have one.
+// The limiters are locked in the order they are given, which must not change between callers.
+func takeTokens(keys ...rateLimiterKey) (time.Duration, bool) {
+	now := time.Now()
+	buckets := make([]*tokenBucket, 0, len(keys))
+	var wait time.Duration
+	for _, k := range keys {
+		if k.limiter == nil {
+			continue
+		}
+		k.limiter.mu.Lock()
+		defer k.limiter.mu.Unlock()
+		b := k.limiter.refill(k.key, now)
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/k.limiter.perSecond*float64(time.Second)))
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0, true
+}
+
+// refill returns the bucket of key with the tokens earned since it was last used, l.mu must be held
+func (l *rateLimiter) refill(key string, now time.Time) *tokenBucket {
+	if now.Sub(l.lastPrune) > archiveLimiterIdleTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > archiveLimiterIdleTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+	return b
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0, 10))
+	assert.Nil(t, newRateLimiter(-1, 10))
+	l := newRateLimiter(30, 0)
+	assert.InDelta(t, 0.5, l.perSecond, 1e-9)
+	assert.InDelta(t, 1, l.burst, 1e-9)
+}
+
+func TestTakeTokens(t *testing.T) {
+	// one token a minute, so that none is earned during the test
+	user := newRateLimiter(1, 2)
+	ip := newRateLimiter(1, 3)
+	keys := []rateLimiterKey{{user, "1"}, {ip, "10.0.0.1"}}
+
+	for range 2 {
+		_, ok := takeTokens(keys...)
+		assert.True(t, ok)
+	}
+	wait, ok := takeTokens(keys...)
+	assert.False(t, ok)
+	assert.Greater(t, wait, time.Duration(0))
+	assert.LessOrEqual(t, wait, time.Minute)
+
+	// the rejected request was not charged to the IP, which still has its last token
+	_, ok = takeTokens(rateLimiterKey{ip, "10.0.0.1"})
+	assert.True(t, ok)
+	_, ok = takeTokens(rateLimiterKey{ip, "10.0.0.1"})
+	assert.False(t, ok)
+
+	// other keys have their own bucket and disabled limits are skipped
+	_, ok = takeTokens(rateLimiterKey{user, "2"}, rateLimiterKey{nil, "10.0.0.1"})
+	assert.True(t, ok)
+	_, ok = takeTokens()
+	assert.True(t, ok)
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := newRateLimiter(60, 5)
+	now := time.Now()
+	b := l.refill("key", now)
+	assert.InDelta(t, 5, b.tokens, 1e-9)
+
+	b.tokens = 0
+	b = l.refill("key", now.Add(2*time.Second))
+	assert.InDelta(t, 2, b.tokens, 1e-9)
+	// never more than the burst
+	b = l.refill("key", now.Add(time.Hour))
+	assert.InDelta(t, 5, b.tokens, 1e-9)
+
+	// idle buckets are dropped
+	l.refill("other", now.Add(time.Hour))
+	later := now.Add(time.Hour + 2*archiveLimiterIdleTTL)
+	l.refill("new", later)
+	assert.NotContains(t, l.buckets, "key")
+	assert.NotContains(t, l.buckets, "other")
+	assert.Contains(t, l.buckets, "new")
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/httpcache"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplRawListing templates.TplName = "repo/raw_listing"
+
+// RawListingEntry is one child of a directory listed under /raw or /media
+type RawListingEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Type is "file", "dir", "symlink" or "submodule"
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+	SHA  string `json:"sha"`
+	Size int64  `json:"size"`
+	// URL is the raw (or media) URL of a file or the listing of a directory, submodules have none
+	URL string `json:"url,omitempty"`
+}
+
+// RawListing is the JSON listing of a directory requested under /raw or /media
+type RawListing struct {
+	Ref        string             `json:"ref"`
+	Commit     string             `json:"commit"`
+	Tree       string             `json:"tree"`
+	Path       string             `json:"path"`
+	ParentURL  string             `json:"parent_url,omitempty"`
+	ArchiveURL string             `json:"archive_url,omitempty"`
+	Entries    []*RawListingEntry `json:"entries"`
+}
+
+// serveRawDirectory lists a directory requested under /raw or /media (handler), as JSON for clients accepting
+// "application/json" and as a minimal HTML index otherwise. The links point to the same handler, so a tool
+// can walk the tree like a static file server.
+func serveRawDirectory(ctx *context.Context, entry *git.TreeEntry, handler string) {
+	asJSON := strings.Contains(ctx.Req.Header.Get("Accept"), "application/json")
+	ctx.Resp.Header().Add("Vary", "Accept")
+	etag := `"` + entry.ID.String() + `-html"`
+	if asJSON {
+		etag = `"` + entry.ID.String() + `-json"`
+	}
+	if httpcache.HandleGenericETagCache(ctx.Req, ctx.Resp, etag) {
+		return
+	}
+
+	tree, err := download_service.GetTree(ctx.Repo.Commit, ctx.Repo.TreePath)
+	if err != nil {
+		ctx.ServerError("GetTree", err)
+		return
+	}
+	entries, err := tree.ListEntries()
+	if err != nil {
+		ctx.ServerError("ListEntries", err)
+		return
+	}
+	entries.Sort()
+
+	refSubURL := ctx.Repo.RefTypeNameSubURL()
+	if refSubURL == "" {
+		refSubURL = "commit/" + ctx.Repo.Commit.ID.String()
+	}
+	baseURL := ctx.Repo.Repository.HTMLURL() + "/" + handler + "/" + refSubURL + "/"
+	listing := &RawListing{
+		Ref:     ctx.Repo.RefFullName.ShortName(),
+		Commit:  ctx.Repo.Commit.ID.String(),
+		Tree:    entry.ID.String(),
+		Path:    ctx.Repo.TreePath,
+		Entries: make([]*RawListingEntry, 0, len(entries)),
+	}
+	if listing.Path != "" {
+		parent := path.Dir(listing.Path)
+		if parent == "." {
+			parent = ""
+		}
+		listing.ParentURL = baseURL + util.PathEscapeSegments(parent)
+	}
+	if !isBrowseOnly(ctx) {
+		// pinned to the listed commit, branch names with slashes cannot be used in the folder download route
+		listing.ArchiveURL = ctx.Repo.Repository.HTMLURL() + "/download/folder/branch/" + listing.Commit + "/" + util.PathEscapeSegments(listing.Path)
+	}
+
+	for _, child := range entries {
+		childPath := path.Join(listing.Path, child.Name())
+		e := &RawListingEntry{
+			Name: child.Name(),
+			Path: childPath,
+			Mode: child.Mode().String(),
+			SHA:  child.ID.String(),
+			URL:  baseURL + util.PathEscapeSegments(childPath),
+		}
+		switch {
+		case child.IsSubModule():
+			e.Type, e.URL = "submodule", ""
+		case child.IsDir():
+			e.Type = "dir"
+			e.URL += "/"
+		case child.IsLink():
+			e.Type = "symlink"
+		default:
+			e.Type = "file"
+			e.Size = child.Size()
+		}
+		listing.Entries = append(listing.Entries, e)
+	}
+
+	if asJSON {
+		ctx.JSON(http.StatusOK, listing)
+		return
+	}
+	ctx.Data["Listing"] = listing
+	ctx.Data["ListingTitle"] = ctx.Repo.Repository.FullName() + "/" + listing.Path
+	ctx.HTML(http.StatusOK, tplRawListing)
+}
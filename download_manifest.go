@@ -0,0 +1,268 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const (
+	folderManifestDefaultLimit = 1000
+	folderManifestMaxLimit     = 10000
+
+	folderSummaryLargestFiles = 5
+)
+
+// FolderManifestEntry describes one entry of a folder manifest
+type FolderManifestEntry struct {
+	Path    string `json:"path"`
+	Mode    string `json:"mode"`
+	SHA     string `json:"sha"`
+	Size    int64  `json:"size"`
+	LFS     bool   `json:"lfs"`
+	LFSSize int64  `json:"lfs_size,omitempty"`
+}
+
+// FolderManifest is the response of the folder manifest endpoint
+type FolderManifest struct {
+	Commit     string                 `json:"commit"`
+	Path       string                 `json:"path"`
+	TotalCount int                    `json:"total_count"`
+	Page       int                    `json:"page"`
+	Limit      int                    `json:"limit"`
+	Entries    []*FolderManifestEntry `json:"entries"`
+}
+
+// FolderSummary is a lightweight size estimate shown before downloading a folder
+type FolderSummary struct {
+	Tree            string               `json:"tree"`
+	EntryCount      int                  `json:"entry_count"`
+	TotalSize       int64                `json:"total_size"`
+	TotalSizeHuman  string               `json:"total_size_human"`
+	LFSSize         int64                `json:"lfs_size"`
+	LFSSizeHuman    string               `json:"lfs_size_human"`
+	LargestFiles    []*FolderSummaryFile `json:"largest_files"`
+	ConfirmRequired bool                 `json:"confirm_required"`
+}
+
+// FolderSummaryFile is one of the largest files listed in a FolderSummary
+type FolderSummaryFile struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	SizeHuman string `json:"size_human"`
+	LFS       bool   `json:"lfs"`
+}
+
+// serveFolderDescription serves "{folder}/manifest" and "{folder}/summary" requests,
+// it returns false if the path does not end with one of them.
+func serveFolderDescription(ctx *context.Context, obs *downloadObservation, treePath, format string) bool {
+	if folderPath, ok := cutFolderPathSuffix(treePath, "manifest"); ok {
+		if commit, decodedPath := getFolderForDownload(ctx, obs, folderPath); commit != nil {
+			serveFolderManifest(ctx, commit, decodedPath, format)
+		}
+		return true
+	}
+	if folderPath, ok := cutFolderPathSuffix(treePath, "summary"); ok && format == "json" {
+		if commit, decodedPath := getFolderForDownload(ctx, obs, folderPath); commit != nil {
+			serveFolderSummary(ctx, commit, decodedPath)
+		}
+		return true
+	}
+	return false
+}
+
+// cutFolderPathSuffix strips a trailing segment like "manifest" from a folder download path
+func cutFolderPathSuffix(treePath, name string) (string, bool) {
+	treePath = strings.TrimSuffix(treePath, "/")
+	if treePath == name {
+		return "", true
+	}
+	return strings.CutSuffix(treePath, "/"+name)
+}
+
+// listFolderEntries returns all entries below treePath recursively, with paths relative to treePath
+func listFolderEntries(commit *git.Commit, treePath string) (git.Entries, error) {
+	tree, err := download_service.GetTree(commit, treePath)
+	if err != nil {
+		return nil, err
+	}
+	return tree.ListEntriesRecursiveWithSize()
+}
+
+// readLFSPointer returns the LFS pointer stored in a blob entry, if there is one
+func readLFSPointer(entry *git.TreeEntry) (lfs.Pointer, bool) {
+	if entry.IsDir() || entry.IsSubModule() || entry.Size() > lfs.MetaFileMaxSize {
+		return lfs.Pointer{}, false
+	}
+	dataRc, err := entry.Blob().DataAsync()
+	if err != nil {
+		log.Error("DataAsync: %v", err)
+		return lfs.Pointer{}, false
+	}
+	defer dataRc.Close()
+	pointer, _ := lfs.ReadPointer(dataRc)
+	return pointer, pointer.IsValid()
+}
+
+func serveFolderManifest(ctx *context.Context, commit *git.Commit, treePath, format string) {
+	entries, err := listFolderEntries(commit, treePath)
+	if err != nil {
+		ctx.ServerError("ListEntriesRecursiveWithSize", err)
+		return
+	}
+
+	page := max(ctx.FormInt("page"), 1)
+	limit := ctx.FormInt("limit")
+	if limit <= 0 {
+		limit = folderManifestDefaultLimit
+	}
+	limit = min(limit, folderManifestMaxLimit)
+
+	start := min((page-1)*limit, len(entries))
+	end := min(start+limit, len(entries))
+
+	manifest := &FolderManifest{
+		Commit:     commit.ID.String(),
+		Path:       treePath,
+		TotalCount: len(entries),
+		Page:       page,
+		Limit:      limit,
+		Entries:    make([]*FolderManifestEntry, 0, end-start),
+	}
+	for _, entry := range entries[start:end] {
+		item := &FolderManifestEntry{
+			Path: entry.Name(),
+			Mode: entry.Mode().String(),
+			SHA:  entry.ID.String(),
+		}
+		if !entry.IsSubModule() {
+			item.Size = entry.Size()
+		}
+		if pointer, ok := readLFSPointer(entry); ok {
+			item.LFS = true
+			item.LFSSize = pointer.Size
+		}
+		manifest.Entries = append(manifest.Entries, item)
+	}
+
+	ctx.SetTotalCountHeader(int64(manifest.TotalCount))
+	if format == "json" {
+		ctx.JSON(http.StatusOK, manifest)
+		return
+	}
+
+	folderName := path.Base(treePath)
+	if treePath == "" {
+		folderName = ctx.Repo.Repository.Name
+	}
+	ctx.Resp.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	ctx.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s-manifest.csv"`, folderName, commit.ID.String()[:7]))
+
+	w := csv.NewWriter(ctx.Resp)
+	_ = w.Write([]string{"path", "mode", "sha", "size", "lfs", "lfs_size"})
+	for _, item := range manifest.Entries {
+		_ = w.Write([]string{
+			item.Path,
+			item.Mode,
+			item.SHA,
+			strconv.FormatInt(item.Size, 10),
+			strconv.FormatBool(item.LFS),
+			strconv.FormatInt(item.LFSSize, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Error("serveFolderManifest: %v", err)
+	}
+}
+
+// getFolderSummary computes the summary of a tree, it is cached per tree SHA as trees are immutable
+func getFolderSummary(tree *git.Tree) (*FolderSummary, error) {
+	hit := true
+	data, err := cache.GetString("folder_summary_"+tree.ID.String(), func() (string, error) {
+		hit = false
+		entries, err := tree.ListEntriesRecursiveWithSize()
+		if err != nil {
+			return "", err
+		}
+		summary := &FolderSummary{Tree: tree.ID.String()}
+		for _, entry := range entries {
+			if entry.IsDir() || entry.IsSubModule() {
+				continue
+			}
+			summary.EntryCount++
+			item := &FolderSummaryFile{Path: entry.Name(), Size: entry.Size()}
+			if pointer, ok := readLFSPointer(entry); ok {
+				item.LFS = true
+				item.Size = pointer.Size
+				summary.LFSSize += pointer.Size
+			}
+			summary.TotalSize += item.Size
+			summary.LargestFiles = insertLargestFile(summary.LargestFiles, item)
+		}
+		for _, item := range summary.LargestFiles {
+			item.SizeHuman = base.FileSize(item.Size)
+		}
+		summary.TotalSizeHuman = base.FileSize(summary.TotalSize)
+		summary.LFSSizeHuman = base.FileSize(summary.LFSSize)
+		bs, err := json.Marshal(summary)
+		return string(bs), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	download_service.ObserveCacheLookup("folder_summary", hit)
+	summary := &FolderSummary{}
+	if err := json.Unmarshal([]byte(data), summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// insertLargestFile keeps the largest folderSummaryLargestFiles entries, sorted by size descending
+func insertLargestFile(files []*FolderSummaryFile, item *FolderSummaryFile) []*FolderSummaryFile {
+	idx := len(files)
+	for idx > 0 && files[idx-1].Size < item.Size {
+		idx--
+	}
+	if idx >= folderSummaryLargestFiles {
+		return files
+	}
+	files = slices.Insert(files, idx, item)
+	if len(files) > folderSummaryLargestFiles {
+		files = files[:folderSummaryLargestFiles]
+	}
+	return files
+}
+
+func serveFolderSummary(ctx *context.Context, commit *git.Commit, treePath string) {
+	tree, err := download_service.GetTree(commit, treePath)
+	if err != nil {
+		ctx.ServerError("GetTree", err)
+		return
+	}
+	summary, err := getFolderSummary(tree)
+	if err != nil {
+		ctx.ServerError("getFolderSummary", err)
+		return
+	}
+	threshold := download_service.Setting().ConfirmSizeThreshold
+	summary.ConfirmRequired = threshold >= 0 && summary.TotalSize > threshold
+	ctx.JSON(http.StatusOK, summary)
+}
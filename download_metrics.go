@@ -0,0 +1,117 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const downloadMetricsNamespace = "gitea"
+
+var (
+	downloadRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: downloadMetricsNamespace,
+		Subsystem: "download",
+		Name:      "requests_total",
+		Help:      "Number of raw, media and archive download requests by handler, format and outcome",
+	}, []string{"handler", "format", "outcome"})
+
+	downloadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: downloadMetricsNamespace,
+		Subsystem: "download",
+		Name:      "bytes_total",
+		Help:      "Number of bytes streamed by raw, media and archive downloads",
+	}, []string{"handler", "format"})
+
+	downloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: downloadMetricsNamespace,
+		Subsystem: "download",
+		Name:      "request_duration_seconds",
+		Help:      "Time spent serving raw, media and archive downloads",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"handler", "format"})
+
+	lfsServes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: downloadMetricsNamespace,
+		Subsystem: "download",
+		Name:      "lfs_serves_total",
+		Help:      "LFS objects served by mode (redirect, proxied or pointer when the object is missing)",
+	}, []string{"mode"})
+)
+
+var registerDownloadMetricsOnce sync.Once
+
+// RegisterDownloadMetrics registers the download metrics, they are exposed on the metrics endpoint
+func RegisterDownloadMetrics() {
+	registerDownloadMetricsOnce.Do(func() {
+		prometheus.MustRegister(
+			downloadRequests,
+			downloadBytes,
+			downloadDuration,
+			lfsServes,
+		)
+		prometheus.MustRegister(download_service.MetricsCollectors()...)
+	})
+}
+
+// downloadObservation is a download request being measured, see observeDownload
+type downloadObservation struct {
+	ctx     *context.Context
+	handler string
+	format  string
+	start   time.Time
+	target  *downloadAuditTarget
+	// page is set when a page or listing was rendered instead of a download
+	page bool
+}
+
+// observeDownload measures a download request, its done method must be deferred by the handler.
+// It then records the download in the download audit log and fires the repository_download webhook event.
+func observeDownload(ctx *context.Context, handler, format string) *downloadObservation {
+	return &downloadObservation{ctx: ctx, handler: handler, format: format, start: time.Now()}
+}
+
+// setTarget records what the handler resolved the request to, the audit log and the webhook payload
+// otherwise use the ref, commit and tree path of the repository context
+func (o *downloadObservation) setTarget(ref, commitID, treePath string) {
+	o.target = &downloadAuditTarget{Ref: ref, Commit: commitID, Path: treePath}
+}
+
+// setPage tells that the handler rendered a page or listing, it is measured but neither audited nor notified
+func (o *downloadObservation) setPage() {
+	o.page = true
+}
+
+func (o *downloadObservation) done() {
+	ctx := o.ctx
+	if !o.page {
+		recordDownloadAudit(ctx, o.handler, o.format, o.target)
+		notifyRepositoryDownload(ctx, o.handler, o.format, o.target)
+	}
+	downloadRequests.WithLabelValues(o.handler, o.format, downloadOutcome(ctx.Resp.WrittenStatus())).Inc()
+	downloadBytes.WithLabelValues(o.handler, o.format).Add(float64(ctx.Resp.WrittenSize()))
+	downloadDuration.WithLabelValues(o.handler, o.format).Observe(time.Since(o.start).Seconds())
+}
+
+func downloadOutcome(status int) string {
+	switch {
+	case status == 0 || status < http.StatusMultipleChoices:
+		return "ok"
+	case status == http.StatusNotModified:
+		return "not_modified"
+	case status < http.StatusBadRequest:
+		return "redirect"
+	case status < http.StatusInternalServerError:
+		return "client_error"
+	default:
+		return "server_error"
+	}
+}
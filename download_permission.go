@@ -0,0 +1,35 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// isBrowseOnly reports whether the doer may read the code of the current repository in the web UI
+// but must not export it in bulk, because they only reach it through browse-only teams
+func isBrowseOnly(ctx *context.Context) bool {
+	if ctx.Repo.Repository == nil || ctx.Repo.IsAdmin() {
+		return false
+	}
+	return download_service.IsBrowseOnlyUser(ctx, ctx.Doer, ctx.Repo.Repository)
+}
+
+// SetFolderDownloadData tells the templates whether the folder download menus must be hidden
+// and whether encrypted zip archives are offered
+func SetFolderDownloadData(ctx *context.Context) {
+	ctx.Data["BrowseOnly"] = isBrowseOnly(ctx)
+	ctx.Data["FolderZipAESEnabled"] = zipAESEnabled(ctx)
+	ctx.Data["FolderArchiveSignaturesEnabled"] = download_service.Setting().ArchiveSignaturesEnabled
+}
+
+// MustAllowBulkDownload denies folder, tree and repository archives to browse-only users
+func MustAllowBulkDownload(ctx *context.Context) {
+	if isBrowseOnly(ctx) {
+		ctx.HTTPError(http.StatusForbidden, "bulk downloads are not allowed for this repository")
+	}
+}
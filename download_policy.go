@@ -0,0 +1,230 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	gocontext "context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/modules/web/middleware"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplDownloadCaptcha templates.TplName = "repo/download_captcha"
+
+// archiveCaptchaSessionKey marks a session which has solved the archive download captcha
+const archiveCaptchaSessionKey = "folder_download_captcha_passed"
+
+// archivePolicyResult is what the archive policy requires of a request before it may generate an archive
+type archivePolicyResult int
+
+const (
+	archivePolicyPass archivePolicyResult = iota
+	archivePolicyBot
+	archivePolicySignIn
+	archivePolicyCaptcha
+)
+
+// evalArchivePolicy applies the archive policy of the repository, or of the instance, to a request
+func evalArchivePolicy(ctx *context.Context) archivePolicyResult {
+	s := download_service.Setting()
+	anonymous, blockBots := s.AnonymousArchivePolicy, s.BlockBots
+	if ctx.Repo.Repository != nil {
+		policy, err := getFolderDownloadPolicy(ctx, ctx.Repo.Repository.ID)
+		if err != nil {
+			// the instance policy still applies if the repository one cannot be read
+			log.Error("getFolderDownloadPolicy: %v", err)
+		} else {
+			anonymous, blockBots = policy.Apply(anonymous, blockBots)
+		}
+	}
+
+	if blockBots && isArchiveBot(ctx.Req.UserAgent(), s.BotUserAgents) {
+		return archivePolicyBot
+	}
+	if ctx.IsSigned {
+		return archivePolicyPass
+	}
+	switch anonymous {
+	case download_service.AnonymousArchivePolicySignIn:
+		return archivePolicySignIn
+	case download_service.AnonymousArchivePolicyCaptcha:
+		if passed, _ := ctx.Session.Get(archiveCaptchaSessionKey).(bool); passed {
+			return archivePolicyPass
+		}
+		return archivePolicyCaptcha
+	}
+	return archivePolicyPass
+}
+
+// checkArchivePolicy applies the anonymous archive policy and the bot detection to an archive request.
+// It returns false if the request must not generate an archive, the response has then been written.
+// Non-interactive clients like "git archive --remote" cannot solve a captcha, they get a 401 instead.
+func checkArchivePolicy(ctx *context.Context, interactive bool) bool {
+	switch evalArchivePolicy(ctx) {
+	case archivePolicyBot:
+		ctx.HTTPError(http.StatusForbidden, "archive downloads are not available to crawlers")
+		return false
+	case archivePolicySignIn:
+		if !interactive {
+			ctx.Resp.Header().Set("WWW-Authenticate", `Basic realm="Gitea"`)
+			ctx.HTTPError(http.StatusUnauthorized)
+			return false
+		}
+		middleware.SetRedirectToCookie(ctx.Resp, setting.AppSubURL+ctx.Req.URL.RequestURI())
+		ctx.Redirect(setting.AppSubURL + "/user/login")
+		return false
+	case archivePolicyCaptcha:
+		if !interactive {
+			ctx.Resp.Header().Set("WWW-Authenticate", `Basic realm="Gitea"`)
+			ctx.HTTPError(http.StatusUnauthorized)
+			return false
+		}
+		renderDownloadCaptcha(ctx, false)
+		return false
+	}
+	return true
+}
+
+// DownloadArchiveCaptchaPost checks the captcha shown for anonymous archive requests and
+// redirects back to the archive, which the session may then download.
+func DownloadArchiveCaptchaPost(ctx *context.Context) {
+	if !context.GetImageCaptcha().VerifyReq(ctx.Req) {
+		renderDownloadCaptcha(ctx, true)
+		return
+	}
+	if err := ctx.Session.Set(archiveCaptchaSessionKey, true); err != nil {
+		ctx.ServerError("Session.Set", err)
+		return
+	}
+	ctx.Redirect(ctx.Req.URL.RequestURI())
+}
+
+func renderDownloadCaptcha(ctx *context.Context, invalid bool) {
+	ctx.Data["Title"] = ctx.Tr("repo.download_folder_captcha_title")
+	ctx.Data["EnableCaptcha"] = true
+	ctx.Data["CaptchaType"] = setting.ImageCaptcha
+	ctx.Data["Captcha"] = context.GetImageCaptcha()
+	ctx.Data["Err_Captcha"] = invalid
+	ctx.Data["ArchiveLink"] = ctx.Req.URL.RequestURI()
+	if invalid {
+		ctx.Flash.Error(ctx.Tr("form.captcha_incorrect"), true)
+	}
+	ctx.HTML(http.StatusForbidden, tplDownloadCaptcha)
+}
+
+// isArchiveBot reports whether the user agent contains one of the configured crawler markers
+func isArchiveBot(userAgent string, markers []string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, marker := range markers {
+		if marker != "" && strings.Contains(userAgent, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func folderDownloadPolicyCacheKey(repoID int64) string {
+	return "folder_download_policy_" + strconv.FormatInt(repoID, 10)
+}
+
+// getFolderDownloadPolicy returns the archive policy of a repository, it is read from the database
+// once and then cached, as every archive request needs it
+func getFolderDownloadPolicy(ctx gocontext.Context, repoID int64) (*repo_model.ArchivePolicy, error) {
+	data, err := cache.GetString(folderDownloadPolicyCacheKey(repoID), func() (string, error) {
+		policy, err := repo_model.GetArchivePolicy(ctx, repoID)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(policy)
+		return string(data), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	policy := &repo_model.ArchivePolicy{}
+	if err := json.Unmarshal([]byte(data), policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// updateFolderDownloadPolicy saves the archive policy of a repository
+func updateFolderDownloadPolicy(ctx gocontext.Context, policy *repo_model.ArchivePolicy) error {
+	err := repo_model.SaveArchivePolicy(ctx, policy)
+	cache.Remove(folderDownloadPolicyCacheKey(policy.RepoID))
+	return err
+}
+
+// setFolderDownloadPolicyData adds the archive policy to the folder archive settings page
+func setFolderDownloadPolicyData(ctx *context.Context) error {
+	policy, err := getFolderDownloadPolicy(ctx, ctx.Repo.Repository.ID)
+	if err != nil {
+		return err
+	}
+	s := download_service.Setting()
+	ctx.Data["Policy"] = policy
+	switch policy.BlockBots {
+	case repo_model.ArchiveBotPolicyBlock:
+		ctx.Data["PolicyBlockBots"] = "true"
+	case repo_model.ArchiveBotPolicyAllow:
+		ctx.Data["PolicyBlockBots"] = "false"
+	default:
+		ctx.Data["PolicyBlockBots"] = ""
+	}
+	ctx.Data["InstanceAnonymousArchives"] = s.AnonymousArchivePolicy
+	ctx.Data["InstanceBlockBots"] = s.BlockBots
+	ctx.Data["InstanceZipAES"] = s.ZipAESEnabled
+	ctx.Data["AnonymousArchivePolicies"] = []string{download_service.AnonymousArchivePolicyAllow, download_service.AnonymousArchivePolicySignIn, download_service.AnonymousArchivePolicyCaptcha}
+	return nil
+}
+
+// folderDownloadPolicyPost saves the archive policy posted from the folder archive settings page
+func folderDownloadPolicyPost(ctx *context.Context) {
+	anonymous := ctx.FormString("anonymous_archives")
+	switch anonymous {
+	case "", download_service.AnonymousArchivePolicyAllow, download_service.AnonymousArchivePolicySignIn, download_service.AnonymousArchivePolicyCaptcha:
+	default:
+		ctx.HTTPError(http.StatusBadRequest, "invalid anonymous archive policy")
+		return
+	}
+	policy, err := getFolderDownloadPolicy(ctx, ctx.Repo.Repository.ID)
+	if err != nil {
+		ctx.ServerError("getFolderDownloadPolicy", err)
+		return
+	}
+	policy.AnonymousArchives = anonymous
+	switch ctx.FormString("block_bots") {
+	case "":
+		policy.BlockBots = repo_model.ArchiveBotPolicyDefault
+	case "true":
+		policy.BlockBots = repo_model.ArchiveBotPolicyBlock
+	case "false":
+		policy.BlockBots = repo_model.ArchiveBotPolicyAllow
+	default:
+		ctx.HTTPError(http.StatusBadRequest, "invalid bot policy")
+		return
+	}
+
+	// the option is not shown while the instance disables encrypted archives
+	if download_service.Setting().ZipAESEnabled {
+		policy.ZipAES = ctx.FormBool("zip_aes")
+	}
+
+	if err := updateFolderDownloadPolicy(ctx, policy); err != nil {
+		ctx.ServerError("updateFolderDownloadPolicy", err)
+		return
+	}
+	ctx.Flash.Success(ctx.Tr("repo.settings.update_settings_success"))
+	ctx.Redirect(ctx.Repo.RepoLink + "/settings/archives")
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplSettingsArchives templates.TplName = "repo/download_prebuilds"
+
+// FolderArchivePrebuilds shows the status of the folder archive pre-builds in the repository settings
+func FolderArchivePrebuilds(ctx *context.Context) {
+	ctx.Data["Title"] = ctx.Tr("repo.settings.folder_archives")
+	ctx.Data["PageIsSettingsFolderArchives"] = true
+	ctx.Data["PrebuildEnabled"] = download_service.Setting().PrebuildEnabled
+
+	prebuilds, err := download_service.ReadPrebuilds(ctx.Repo.Repository.ID)
+	if err != nil {
+		ctx.ServerError("ReadPrebuilds", err)
+		return
+	}
+	ctx.Data["Prebuilds"] = prebuilds
+	if err := setFolderArchiveExportData(ctx); err != nil {
+		ctx.ServerError("setFolderArchiveExportData", err)
+		return
+	}
+	if err := setFolderDownloadPolicyData(ctx); err != nil {
+		ctx.ServerError("setFolderDownloadPolicyData", err)
+		return
+	}
+	ctx.HTML(http.StatusOK, tplSettingsArchives)
+}
+
+// FolderArchivePrebuildsPost saves the export settings or the archive policy, or queues a pre-build of
+// the default branch, for example after the config was fixed
+func FolderArchivePrebuildsPost(ctx *context.Context) {
+	switch ctx.FormString("action") {
+	case "export":
+		folderArchiveExportPost(ctx)
+		return
+	case "policy":
+		folderDownloadPolicyPost(ctx)
+		return
+	}
+	if !download_service.Setting().PrebuildEnabled || ctx.Repo.Repository.IsEmpty {
+		ctx.NotFound(nil)
+		return
+	}
+	commitID, err := ctx.Repo.GitRepo.GetBranchCommitID(ctx.Repo.Repository.DefaultBranch)
+	if err != nil {
+		ctx.ServerError("GetBranchCommitID", err)
+		return
+	}
+	if err := download_service.QueuePrebuild(ctx.Repo.Repository.ID, git.RefNameFromBranch(ctx.Repo.Repository.DefaultBranch), commitID); err != nil {
+		ctx.ServerError("QueuePrebuild", err)
+		return
+	}
+	ctx.Flash.Success(ctx.Tr("repo.settings.folder_archives_queued"))
+	ctx.Redirect(ctx.Repo.RepoLink + "/settings/archives")
+}
@@ -0,0 +1,219 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/httpcache"
+	"code.gitea.io/gitea/modules/httplib"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/routers/common"
+	"code.gitea.io/gitea/services/context"
+)
+
+// rawLinesMaxSize limits the size of a "?lines=" excerpt, it is buffered to report the lines actually returned
+const rawLinesMaxSize = 10 << 20
+
+// serveRawBlob serves a git blob under /raw: "?lines=N-M" returns only those lines, a Range header only those
+// bytes and anything else the whole blob.
+func serveRawBlob(ctx *context.Context, blob *git.Blob, lastModified *time.Time) error {
+	if lines := ctx.FormString("lines"); lines != "" {
+		// the digest of the blob does not describe an excerpt
+		setObjectIDHeader(ctx.Resp, blob.ID)
+		return serveBlobLines(ctx, blob, lines, lastModified)
+	}
+	setBlobDigestHeaders(ctx, blob)
+	if ctx.Req.Header.Get("Range") != "" {
+		return serveBlobRange(ctx, blob, lastModified)
+	}
+	return common.ServeBlob(ctx.Base, ctx.Repo.Repository, ctx.Repo.TreePath, blob, lastModified)
+}
+
+func rawBlobServeOptions(ctx *context.Context) *httplib.ServeHeaderOptions {
+	repo := ctx.Repo.Repository
+	_ = repo.LoadOwner(ctx)
+	return &httplib.ServeHeaderOptions{
+		Filename:      path.Base(ctx.Repo.TreePath),
+		CacheIsPublic: !repo.IsPrivate && repo.Owner != nil && repo.Owner.Visibility == structs.VisibleTypePublic,
+		CacheDuration: setting.StaticCacheTime,
+	}
+}
+
+// serveBlobRange serves a Range request on a git blob, net/http handles suffix, multiple and If-Range ranges
+// on top of a seeker which only reads the blob from where the first range starts.
+func serveBlobRange(ctx *context.Context, blob *git.Blob, lastModified *time.Time) error {
+	if httpcache.HandleGenericETagTimeCache(ctx.Req, ctx.Resp, `"`+blob.ID.String()+`"`, lastModified) {
+		return nil
+	}
+
+	rs := &blobReadSeeker{blob: blob, size: blob.Size()}
+	defer func() {
+		if err := rs.Close(); err != nil {
+			log.Error("serveBlobRange: Close: %v", err)
+		}
+	}()
+	httplib.ServeContentByReadSeeker(ctx.Req, ctx.Resp, lastModified, rs, rawBlobServeOptions(ctx))
+	return nil
+}
+
+// blobReadSeeker makes a git blob seekable: seeking forward discards data, seeking backward reopens the blob
+type blobReadSeeker struct {
+	blob  *git.Blob
+	size  int64
+	pos   int64
+	rc    io.ReadCloser
+	rcPos int64
+}
+
+func (r *blobReadSeeker) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if r.rc == nil || r.rcPos > r.pos {
+		if err := r.Close(); err != nil {
+			return 0, err
+		}
+		rc, err := r.blob.DataAsync()
+		if err != nil {
+			return 0, err
+		}
+		r.rc, r.rcPos = rc, 0
+	}
+	if r.rcPos < r.pos {
+		n, err := io.CopyN(io.Discard, r.rc, r.pos-r.rcPos)
+		r.rcPos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.rc.Read(p)
+	r.pos += int64(n)
+	r.rcPos += int64(n)
+	return n, err
+}
+
+func (r *blobReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("blobReadSeeker: negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *blobReadSeeker) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	err := r.rc.Close()
+	r.rc = nil
+	return err
+}
+
+// parseLineRange parses "N", "N-M" or "N-" (1-based, inclusive), end is 0 for "up to the last line"
+func parseLineRange(value string) (start, end int64, err error) {
+	first, last, isRange := strings.Cut(value, "-")
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid first line %q", first)
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	if last == "" {
+		return start, 0, nil
+	}
+	end, err = strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid last line %q", last)
+	}
+	return start, end, nil
+}
+
+// serveBlobLines serves the selected lines of a text blob as plain text. The blob is only read up to the last
+// selected line and X-Gitea-Lines reports the lines actually returned, which can end before the requested ones.
+func serveBlobLines(ctx *context.Context, blob *git.Blob, lines string, lastModified *time.Time) error {
+	start, end, err := parseLineRange(lines)
+	if err != nil {
+		ctx.HTTPError(http.StatusBadRequest, err.Error())
+		return nil
+	}
+	if httpcache.HandleGenericETagTimeCache(ctx.Req, ctx.Resp, `"`+blob.ID.String()+"-lines-"+lines+`"`, lastModified) {
+		return nil
+	}
+
+	dataRc, err := blob.DataAsync()
+	if err != nil {
+		return err
+	}
+	defer dataRc.Close()
+
+	br := bufio.NewReader(dataRc)
+	head, err := br.Peek(1024)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		ctx.HTTPError(http.StatusBadRequest, "lines can only be selected in text files")
+		return nil
+	}
+
+	var out bytes.Buffer
+	var lastLine int64
+	for line := int64(1); end == 0 || line <= end; {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 && line >= start {
+			if out.Len()+len(chunk) > rawLinesMaxSize {
+				ctx.HTTPError(http.StatusRequestEntityTooLarge, "the selected lines are too large, use a byte range instead")
+				return nil
+			}
+			out.Write(chunk)
+			lastLine = line
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil && err != bufio.ErrBufferFull {
+			return err
+		}
+		if err == nil {
+			line++
+		}
+	}
+	if lastLine == 0 {
+		ctx.Resp.Header().Set("Content-Range", "lines */*")
+		ctx.HTTPError(http.StatusRequestedRangeNotSatisfiable, "the file has fewer lines than requested")
+		return nil
+	}
+
+	opts := rawBlobServeOptions(ctx)
+	opts.ContentType, opts.ContentTypeCharset, opts.Disposition = "text/plain", "utf-8", "inline"
+	size := int64(out.Len())
+	opts.ContentLength = &size
+	if lastModified != nil {
+		opts.LastModified = *lastModified
+	}
+	httplib.ServeSetHeaders(ctx.Resp, opts)
+	ctx.Resp.Header().Set("X-Gitea-Lines", fmt.Sprintf("%d-%d", start, lastLine))
+	ctx.Resp.WriteHeader(http.StatusOK)
+	_, _ = ctx.Resp.Write(out.Bytes())
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"io"
+	"testing"
+
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/services/contexttest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLineRange(t *testing.T) {
+	for value, expected := range map[string][2]int64{
+		"3":   {3, 3},
+		"1-1": {1, 1},
+		"2-5": {2, 5},
+		"7-":  {7, 0},
+	} {
+		start, end, err := parseLineRange(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, [2]int64{start, end}, value)
+	}
+
+	for _, value := range []string{"", "0", "-3", "a", "1-a", "5-2", "1-2-3", " 1"} {
+		_, _, err := parseLineRange(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBlobReadSeeker(t *testing.T) {
+	unittest.PrepareTestEnv(t)
+	ctx, _ := contexttest.MockContext(t, "user2/repo1/raw/branch/master/README.md")
+	contexttest.LoadRepo(t, ctx, 1)
+	contexttest.LoadGitRepo(t, ctx)
+	defer ctx.Repo.GitRepo.Close()
+	commit, err := ctx.Repo.GitRepo.GetBranchCommit("master")
+	require.NoError(t, err)
+	blob, err := commit.GetBlobByPath("README.md")
+	require.NoError(t, err)
+
+	const content = "# repo1\n\nDescription for repo1"
+	rs := &blobReadSeeker{blob: blob, size: blob.Size()}
+	defer rs.Close()
+	read := func(n int) string {
+		buf := make([]byte, n)
+		n, err := io.ReadFull(rs, buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	// net/http seeks to the end for the size and back before reading the range
+	pos, err := rs.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(content), pos)
+	_, err = rs.Seek(10, io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, content[10:21], read(11))
+
+	// forward within the open reader
+	_, err = rs.Seek(2, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, content[23:27], read(4))
+
+	// backward reopens the blob
+	_, err = rs.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, content[2:7], read(5))
+
+	_, err = rs.Seek(-5, io.SeekEnd)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(rs)
+	require.NoError(t, err)
+	assert.Equal(t, content[len(content)-5:], string(rest))
+	n, err := rs.Read(make([]byte, 1))
+	assert.Zero(t, n)
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = rs.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+}
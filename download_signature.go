@@ -0,0 +1,165 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/globallock"
+	"code.gitea.io/gitea/modules/httplib"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/process"
+	"code.gitea.io/gitea/modules/storage"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/modules/util"
+	asymkey_service "code.gitea.io/gitea/services/asymkey"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplDownloadSignature templates.TplName = "repo/download_signature"
+
+// folderArchiveSignatureNamespace is the namespace of SSH signatures, "ssh-keygen -Y verify" must be given the same
+const folderArchiveSignatureNamespace = "file"
+
+// folderArchiveSignatureExt returns the extension of detached signatures made with a key of the given format
+func folderArchiveSignatureExt(format string) string {
+	if format == git.SigningKeyFormatSSH {
+		return ".sig"
+	}
+	return ".asc"
+}
+
+// getOrCreateFolderArchiveSignature returns the detached signature of a cached archive made with the instance
+// signing key. Signatures are cached next to the archive, under a name derived from the key so that they are
+// made again once the key changes.
+func getOrCreateFolderArchiveSignature(ctx *context.Context, a *download_service.CachedArchive, signingKey *git.SigningKey) ([]byte, error) {
+	keyHash := sha256.Sum256([]byte(signingKey.Format + "\x00" + signingKey.KeyID))
+	sigPath := download_service.ArchiveCachePath(ctx.Repo.Repository.ID, a.Key, "signature-"+hex.EncodeToString(keyHash[:8])+folderArchiveSignatureExt(signingKey.Format))
+
+	releaser, err := globallock.Lock(ctx, "folder_archive_signature_"+a.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer releaser()
+
+	if obj, err := storage.RepoArchives.Open(sigPath); err == nil {
+		defer obj.Close()
+		download_service.ObserveCacheLookup("folder_archive_signature", true)
+		return io.ReadAll(obj)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	download_service.ObserveCacheLookup("folder_archive_signature", false)
+
+	if len(a.Parts) != 1 {
+		return nil, fmt.Errorf("archive %s has %d parts, only whole archives are signed", a.Key, len(a.Parts))
+	}
+	archive, err := storage.RepoArchives.Open(download_service.ArchiveCachePath(ctx.Repo.Repository.ID, a.Key, a.Parts[0].Name))
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var args []string
+	if signingKey.Format == git.SigningKeyFormatSSH {
+		// like git, the key is a private key file or a public key file whose private key is in the agent
+		args = []string{"ssh-keygen", "-Y", "sign", "-n", folderArchiveSignatureNamespace, "-f", signingKey.KeyID}
+	} else {
+		args = []string{"gpg", "--batch", "--armor", "--detach-sign", "--local-user", signingKey.KeyID}
+	}
+	stdout, stderr, err := process.GetManager().ExecDirEnvStdIn(ctx, -1, ctx.Repo.Repository.RepoPath(),
+		"sign folder archive "+a.Name, nil, archive, args[0], args[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w, %s", args[0], err, stderr)
+	}
+
+	if _, err := storage.RepoArchives.Save(sigPath, strings.NewReader(stdout), int64(len(stdout))); err != nil {
+		log.Error("Unable to cache the signature of folder archive %s: %v", a.Key, err)
+	}
+	return []byte(stdout), nil
+}
+
+// serveFolderArchiveSignature serves the detached signature ("?signature=true") of a folder archive,
+// or renders the page explaining how to verify it ("?verify=true"). Both sign the archive in the archive cache,
+// and the page links to that cached object, so the archive downloaded from it is the one that was signed.
+func serveFolderArchiveSignature(ctx *context.Context, commit *git.Commit, treePath, baseName, format string, opts *download_service.ArchiveOptions, verifyPage bool) {
+	if !download_service.Setting().ArchiveSignaturesEnabled {
+		ctx.NotFound(errors.New("archive signatures are disabled"))
+		return
+	}
+	repoPath := ctx.Repo.Repository.RepoPath()
+	signingKey, signer := asymkey_service.SigningKey(ctx, repoPath)
+	if signingKey == nil {
+		ctx.NotFound(errors.New("no instance signing key is configured"))
+		return
+	}
+
+	release := startArchiveJob(ctx)
+	if release == nil {
+		return
+	}
+	a, err := download_service.GetOrCreateCachedArchive(ctx, ctx.Repo.Repository, ctx.Repo.GitRepo, commit, treePath, baseName, format, opts, 0)
+	var signature []byte
+	if err == nil {
+		signature, err = getOrCreateFolderArchiveSignature(ctx, a, signingKey)
+	}
+	release()
+	if err != nil {
+		ctx.ServerError("SignFolderArchive", err)
+		return
+	}
+
+	sigName := a.Name + folderArchiveSignatureExt(signingKey.Format)
+	if !verifyPage {
+		contentType := "application/pgp-signature"
+		if signingKey.Format == git.SigningKeyFormatSSH {
+			contentType = "text/plain"
+		}
+		httplib.ServeSetHeaders(ctx.Resp, &httplib.ServeHeaderOptions{
+			ContentType:   contentType,
+			ContentLength: util.ToPointer(int64(len(signature))),
+			Filename:      sigName,
+		})
+		_, _ = ctx.Resp.Write(signature)
+		return
+	}
+
+	publicKey, _, err := asymkey_service.PublicSigningKey(ctx, repoPath)
+	if err != nil {
+		ctx.ServerError("PublicSigningKey", err)
+		return
+	}
+
+	// the signature link is pinned to the commit, the archive of a branch changes with every push
+	query := url.Values{"format": {format}, "symlinks": {opts.Symlinks}, "signature": {"true"}}
+	if opts.EOL != "" {
+		query.Set("eol", opts.EOL)
+	}
+	if opts.Mtime != "" {
+		query.Set("mtime", opts.Mtime)
+	}
+	signatureLink := ctx.Repo.RepoLink + "/download/folder/branch/" + a.Commit + "/" + util.PathEscapeSegments(treePath) + "?" + query.Encode()
+
+	ctx.Data["Title"] = ctx.Tr("repo.download_folder_signature_title")
+	ctx.Data["Archive"] = a
+	ctx.Data["ArchiveLink"] = ctx.Repo.RepoLink + "/download/folder-part/" + a.Key + "/" + url.PathEscape(a.Parts[0].Name)
+	ctx.Data["SignatureLink"] = signatureLink
+	ctx.Data["SignatureName"] = sigName
+	ctx.Data["SignatureFormat"] = signingKey.Format
+	ctx.Data["IsSSHSignature"] = signingKey.Format == git.SigningKeyFormatSSH
+	ctx.Data["SignatureNamespace"] = folderArchiveSignatureNamespace
+	ctx.Data["Signer"] = signer
+	ctx.Data["PublicKey"] = publicKey
+	ctx.HTML(http.StatusOK, tplDownloadSignature)
+}
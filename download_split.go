@@ -0,0 +1,63 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// queueFolderArchiveSplit queues the build of a split archive unless it is queued or running already.
+// A failed build is only queued again when retry is set.
+func queueFolderArchiveSplit(ctx *context.Context, task *download_service.SplitTask, retry bool) (*download_service.SplitStatus, bool) {
+	status, err := download_service.ReadSplitStatus(task.RepoID, task.Key)
+	if err != nil {
+		ctx.ServerError("ReadSplitStatus", err)
+		return nil, false
+	}
+	if status != nil && !status.Stale() && (status.Status != download_service.SplitFailed || !retry) {
+		return status, true
+	}
+
+	// queuing is cheap, but each build still takes a token from the archive rate limit of the requester
+	if wait, ok := allowArchiveRequest(ctx); !ok {
+		archiveTooManyRequests(ctx, wait)
+		return nil, false
+	}
+	if status, err = download_service.QueueSplit(task); err != nil {
+		ctx.ServerError("QueueSplit", err)
+		return nil, false
+	}
+	return status, true
+}
+
+// FolderArchiveSplitStatusJSON reports the build status of a split folder archive to its parts page
+func FolderArchiveSplitStatusJSON(ctx *context.Context) {
+	key := ctx.PathParam("key")
+	if _, err := download_service.ReadCachedArchive(ctx.Repo.Repository.ID, key); err == nil {
+		ctx.JSON(http.StatusOK, &download_service.SplitStatus{Status: download_service.SplitDone})
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		ctx.ServerError("ReadCachedArchive", err)
+		return
+	}
+	status, err := download_service.ReadSplitStatus(ctx.Repo.Repository.ID, key)
+	if err != nil {
+		ctx.ServerError("ReadSplitStatus", err)
+		return
+	}
+	if status == nil {
+		ctx.NotFound(nil)
+		return
+	}
+	// the error may name server paths, only the repository administrators see it
+	if !ctx.Repo.IsAdmin() {
+		status.Error = ""
+	}
+	ctx.JSON(http.StatusOK, status)
+}
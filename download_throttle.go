@@ -0,0 +1,391 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	gocontext "context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const (
+	// downloadThrottleChunk bounds how many bytes are reserved from a bandwidth bucket at once
+	downloadThrottleChunk = 32 * 1024
+	// downloadUsageSyncInterval is how often the daily usage is written to and read from the database
+	downloadUsageSyncInterval = 10 * time.Second
+	// downloadUsageRetention is how long the daily usage is kept for the usage pages
+	downloadUsageRetention = 90 * 24 * time.Hour
+)
+
+var errDownloadQuotaExceeded = errors.New("daily download quota exceeded")
+
+// downloadKind selects the bandwidth caps applied to a download
+type downloadKind int
+
+const (
+	downloadKindRaw downloadKind = iota
+	downloadKindArchive
+)
+
+var (
+	archiveUserBandwidth = newBandwidthLimiters()
+	rawUserBandwidth     = newBandwidthLimiters()
+	downloadQuotaUsage   = &dailyUsage{}
+)
+
+// throttleDownload applies the bandwidth caps and daily quotas to the response of a download. It is called once
+// the requested object is resolved, only the body of a successful response is charged against the quotas.
+// It returns false if a quota is already exhausted, in which case a 429 has been written.
+func throttleDownload(ctx *context.Context, kind downloadKind) bool {
+	s := download_service.Setting()
+	perConnection, perUser, users := s.RawBandwidthPerConnection, s.RawBandwidthPerUser, rawUserBandwidth
+	if kind == downloadKindArchive {
+		perConnection, perUser, users = s.ArchiveBandwidthPerConnection, s.ArchiveBandwidthPerUser, archiveUserBandwidth
+	}
+
+	userKey := "ip:" + archiveRequestIP(ctx)
+	user := usageSubject{kind: repo_model.DownloadUsageIP, ip: archiveRequestIP(ctx)}
+	if ctx.Doer != nil {
+		userKey = "user:" + strconv.FormatInt(ctx.Doer.ID, 10)
+		user = usageSubject{kind: repo_model.DownloadUsageUser, ownerID: ctx.Doer.ID}
+	}
+	w := &throttledResponseWriter{ctx: ctx}
+	if s.DailyQuotaPerUser >= 0 {
+		w.quotas = append(w.quotas, downloadQuota{usageSubject: user, limit: s.DailyQuotaPerUser})
+	}
+	if s.DailyQuotaPerOrg >= 0 && ctx.Repo.Owner != nil && ctx.Repo.Owner.IsOrganization() {
+		w.quotas = append(w.quotas, downloadQuota{usageSubject: usageSubject{kind: repo_model.DownloadUsageOrg, ownerID: ctx.Repo.Owner.ID}, limit: s.DailyQuotaPerOrg})
+	}
+	for _, q := range w.quotas {
+		if !downloadQuotaUsage.allowed(ctx, q, 0) {
+			ctx.Resp.Header().Set("Retry-After", strconv.Itoa(int(time.Until(nextUTCDay(time.Now())).Seconds())+1))
+			ctx.HTTPError(http.StatusTooManyRequests, errDownloadQuotaExceeded.Error())
+			return false
+		}
+	}
+
+	if perConnection > 0 {
+		w.limiters = append(w.limiters, newBandwidthLimiter(perConnection))
+	}
+	if perUser > 0 {
+		w.limiters = append(w.limiters, users.get(userKey, perUser))
+	}
+	if len(w.quotas) == 0 && len(w.limiters) == 0 {
+		return true
+	}
+
+	// wrap the underlying writer, so everything streamed through ctx.Resp is accounted for
+	if resp, ok := ctx.Resp.(*context.Response); ok {
+		w.ResponseWriter = resp.ResponseWriter
+		resp.ResponseWriter = w
+	}
+	return true
+}
+
+// throttledResponseWriter enforces bandwidth caps and daily quotas on every write of a response.
+// Error pages, like a 400 for an invalid line range, pass through unthrottled and are not charged.
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	ctx      gocontext.Context
+	limiters []*bandwidthLimiter
+	quotas   []downloadQuota
+	status   int
+}
+
+func (w *throttledResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *throttledResponseWriter) Write(bs []byte) (int, error) {
+	if w.status >= http.StatusMultipleChoices {
+		return w.ResponseWriter.Write(bs)
+	}
+	written := 0
+	for len(bs) > 0 {
+		chunk := bs[:min(len(bs), downloadThrottleChunk)]
+		for _, q := range w.quotas {
+			if !downloadQuotaUsage.allowed(w.ctx, q, int64(len(chunk))) {
+				return written, errDownloadQuotaExceeded
+			}
+		}
+		for _, l := range w.limiters {
+			if err := l.wait(w.ctx, len(chunk)); err != nil {
+				return written, err
+			}
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		for _, q := range w.quotas {
+			downloadQuotaUsage.add(q.usageSubject, int64(n))
+		}
+		if err != nil {
+			return written, err
+		}
+		bs = bs[n:]
+	}
+	return written, nil
+}
+
+func (w *throttledResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// allowRedirectedDownload is asked before a download of n bytes is redirected to a signed URL of the storage,
+// where the bytes do not pass through the throttled writer. A download under a bandwidth cap, or one which does not
+// fit in the remaining quotas, is not to be redirected but streamed, so that the caps and quotas are enforced.
+// Otherwise the n bytes are charged against the quotas up front and true is returned.
+func allowRedirectedDownload(ctx *context.Context, n int64) bool {
+	resp, ok := ctx.Resp.(*context.Response)
+	if !ok {
+		return true
+	}
+	w, ok := resp.ResponseWriter.(*throttledResponseWriter)
+	if !ok {
+		return true
+	}
+	if len(w.limiters) > 0 {
+		return false
+	}
+	for _, q := range w.quotas {
+		if !downloadQuotaUsage.allowed(ctx, q, n) {
+			return false
+		}
+	}
+	for _, q := range w.quotas {
+		downloadQuotaUsage.add(q.usageSubject, n)
+	}
+	return true
+}
+
+// bandwidthLimiter is a token bucket of bytes, refilled at rate bytes per second
+type bandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// wait reserves n bytes and blocks until they may be sent. The bucket may go negative,
+// so concurrent writers sharing a limiter queue up behind each other.
+func (l *bandwidthLimiter) wait(ctx gocontext.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bandwidthLimiters holds the limiters shared by all connections of a user
+type bandwidthLimiters struct {
+	mu        sync.Mutex
+	limiters  map[string]*bandwidthLimiter
+	lastPrune time.Time
+}
+
+func newBandwidthLimiters() *bandwidthLimiters {
+	return &bandwidthLimiters{limiters: make(map[string]*bandwidthLimiter), lastPrune: time.Now()}
+}
+
+func (ls *bandwidthLimiters) get(key string, bytesPerSecond int64) *bandwidthLimiter {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(ls.lastPrune) > archiveLimiterIdleTTL {
+		for k, l := range ls.limiters {
+			l.mu.Lock()
+			idle := now.Sub(l.last) > archiveLimiterIdleTTL
+			l.mu.Unlock()
+			if idle {
+				delete(ls.limiters, k)
+			}
+		}
+		ls.lastPrune = now
+	}
+
+	l, ok := ls.limiters[key]
+	if !ok {
+		l = newBandwidthLimiter(bytesPerSecond)
+		ls.limiters[key] = l
+	}
+	return l
+}
+
+// usageSubject is whose downloads are counted against a daily quota
+type usageSubject struct {
+	kind    repo_model.DownloadUsageKind
+	ownerID int64
+	ip      string
+}
+
+type downloadQuota struct {
+	usageSubject
+	limit int64
+}
+
+type dailyUsageKey struct {
+	day timeutil.TimeStamp
+	usageSubject
+}
+
+type storedUsage struct {
+	bytes int64
+	read  time.Time
+}
+
+// dailyUsage counts the bytes served per subject during the current UTC day. The counts are kept in the
+// database, so the quotas hold across restarts and for all Gitea instances sharing it. Each process writes
+// the bytes it served every downloadUsageSyncInterval and rereads the counts as old, so a quota may be
+// overrun by what the other instances served in the meantime.
+type dailyUsage struct {
+	mu      sync.Mutex
+	day     timeutil.TimeStamp
+	stored  map[usageSubject]storedUsage
+	pending map[dailyUsageKey]int64
+	syncing sync.Once
+
+	lastCleanup int64 // only used by the sync loop
+}
+
+// resetIfNewDay must be called with the lock held. The pending bytes of the previous day are kept until written.
+func (u *dailyUsage) resetIfNewDay() {
+	today := timeutil.TimeStamp(time.Now().UTC().Truncate(24 * time.Hour).Unix())
+	if u.stored == nil || u.day != today {
+		u.day = today
+		u.stored = make(map[usageSubject]storedUsage)
+	}
+	if u.pending == nil {
+		u.pending = make(map[dailyUsageKey]int64)
+	}
+}
+
+// used returns the bytes served to the subject today, reading the database if the stored count is old
+func (u *dailyUsage) used(ctx gocontext.Context, s usageSubject) int64 {
+	u.mu.Lock()
+	u.resetIfNewDay()
+	day := u.day
+	stored, ok := u.stored[s]
+	if ok && time.Since(stored.read) < downloadUsageSyncInterval {
+		defer u.mu.Unlock()
+		return stored.bytes + u.pending[dailyUsageKey{day, s}]
+	}
+	u.mu.Unlock()
+
+	bytes, err := repo_model.GetDownloadUsageBytes(ctx, day, s.kind, s.ownerID, s.ip)
+	if err != nil {
+		// go on with what is known rather than failing every download while the database is unavailable
+		log.Error("GetDownloadUsageBytes: %v", err)
+		bytes = stored.bytes
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.day == day {
+		u.stored[s] = storedUsage{bytes: bytes, read: time.Now()}
+	}
+	return bytes + u.pending[dailyUsageKey{day, s}]
+}
+
+// allowed reports whether n more bytes fit in the quota, n = 0 checks that the quota is not exhausted yet
+func (u *dailyUsage) allowed(ctx gocontext.Context, q downloadQuota, n int64) bool {
+	used := u.used(ctx, q.usageSubject)
+	if n == 0 {
+		return used < q.limit
+	}
+	return used+n <= q.limit
+}
+
+func (u *dailyUsage) add(s usageSubject, n int64) {
+	u.syncing.Do(func() {
+		go graceful.GetManager().RunWithShutdownContext(u.syncLoop)
+	})
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.resetIfNewDay()
+	u.pending[dailyUsageKey{u.day, s}] += n
+}
+
+func (u *dailyUsage) syncLoop(ctx gocontext.Context) {
+	ticker := time.NewTicker(downloadUsageSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.flush(ctx)
+		case <-ctx.Done():
+			// the shutdown context is done, the last bytes are written before the hammer
+			u.flush(graceful.GetManager().HammerContext())
+			return
+		}
+	}
+}
+
+// flush writes the pending bytes to the database, those which could not be written are retried next time
+func (u *dailyUsage) flush(ctx gocontext.Context) {
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = make(map[dailyUsageKey]int64)
+	u.mu.Unlock()
+
+	for k, n := range pending {
+		if err := repo_model.IncreaseDownloadUsage(ctx, k.day, k.kind, k.ownerID, k.ip, n); err != nil {
+			log.Error("IncreaseDownloadUsage: %v", err)
+			u.mu.Lock()
+			u.pending[k] += n
+			u.mu.Unlock()
+			continue
+		}
+		// the stored count misses what has just been written, reread it
+		u.mu.Lock()
+		delete(u.stored, k.usageSubject)
+		u.mu.Unlock()
+	}
+
+	// keep the usage of the last downloadUsageRetention days for the usage pages
+	if day := time.Now().UTC().Truncate(24 * time.Hour); day.Unix() != u.lastCleanup {
+		if err := repo_model.DeleteDownloadUsagesBefore(ctx, timeutil.TimeStamp(day.Add(-downloadUsageRetention).Unix())); err != nil {
+			log.Error("DeleteDownloadUsagesBefore: %v", err)
+		} else {
+			u.lastCleanup = day.Unix()
+		}
+	}
+}
+
+func nextUTCDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
@@ -0,0 +1,50 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthLimiter(t *testing.T) {
+	l := newBandwidthLimiter(1000)
+
+	// the first second worth of bytes goes out at once
+	start := time.Now()
+	require.NoError(t, l.wait(t.Context(), 1000))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// then the bucket is empty and the next bytes wait for their share
+	start = time.Now()
+	require.NoError(t, l.wait(t.Context(), 100))
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+
+	// a reservation stays taken when the wait is cancelled, the next writer queues behind it
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	assert.ErrorIs(t, l.wait(ctx, 10000), context.Canceled)
+	l.mu.Lock()
+	assert.Less(t, l.tokens, float64(-9000))
+	l.mu.Unlock()
+}
+
+func TestBandwidthLimiters(t *testing.T) {
+	ls := newBandwidthLimiters()
+	a := ls.get("user:1", 1000)
+	assert.Same(t, a, ls.get("user:1", 1000))
+	assert.NotSame(t, a, ls.get("user:2", 1000))
+
+	// idle limiters are dropped
+	a.mu.Lock()
+	a.last = time.Now().Add(-2 * archiveLimiterIdleTTL)
+	a.mu.Unlock()
+	ls.lastPrune = time.Now().Add(-2 * archiveLimiterIdleTTL)
+	assert.NotSame(t, a, ls.get("user:1", 1000))
+	assert.Len(t, ls.limiters, 2)
+}
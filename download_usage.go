@@ -0,0 +1,144 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const (
+	tplSettingsDownloadUsage templates.TplName = "user/settings/download_usage"
+	tplAdminDownloadUsage    templates.TplName = "admin/download_usage"
+)
+
+// downloadUsageSettingsDays is how many days the usage page of the user settings shows
+const downloadUsageSettingsDays = 30
+
+// DownloadUsageEnabled reports whether downloads are counted against a daily quota
+func DownloadUsageEnabled() bool {
+	s := download_service.Setting()
+	return s.DailyQuotaPerUser >= 0 || s.DailyQuotaPerOrg >= 0
+}
+
+func utcDay(t time.Time) timeutil.TimeStamp {
+	return timeutil.TimeStamp(t.UTC().Truncate(24 * time.Hour).Unix())
+}
+
+// DownloadUsageSettings shows the signed-in user what they downloaded today and during the last days
+func DownloadUsageSettings(ctx *context.Context) {
+	if !DownloadUsageEnabled() {
+		ctx.NotFound(nil)
+		return
+	}
+	ctx.Data["Title"] = ctx.Tr("settings.download_usage")
+	ctx.Data["PageIsSettingsDownloadUsage"] = true
+
+	today := utcDay(time.Now())
+	subject := usageSubject{kind: repo_model.DownloadUsageUser, ownerID: ctx.Doer.ID}
+	usages, err := db.Find[repo_model.DownloadUsage](ctx, repo_model.FindDownloadUsageOptions{
+		ListOptions: db.ListOptionsAll,
+		Kind:        subject.kind,
+		OwnerID:     subject.ownerID,
+		Since:       today.AddDuration(-downloadUsageSettingsDays * 24 * time.Hour),
+		Until:       today.Add(-1),
+	})
+	if err != nil {
+		ctx.ServerError("FindDownloadUsages", err)
+		return
+	}
+
+	// today's count includes what this process has not written yet
+	ctx.Data["TodayBytes"] = downloadQuotaUsage.used(ctx, subject)
+	ctx.Data["DailyQuota"] = download_service.Setting().DailyQuotaPerUser
+	ctx.Data["ResetsAt"] = timeutil.TimeStamp(nextUTCDay(time.Now()).Unix())
+	ctx.Data["Usages"] = usages
+	ctx.Data["UsageDays"] = downloadUsageSettingsDays
+	ctx.HTML(http.StatusOK, tplSettingsDownloadUsage)
+}
+
+// downloadUsageRow is a line of the download usage report
+type downloadUsageRow struct {
+	*repo_model.DownloadUsage
+	Owner *user_model.User
+	Quota int64
+}
+
+// DownloadUsageReport lists the users, organizations and anonymous IPs by the bytes they downloaded on a day
+func DownloadUsageReport(ctx *context.Context) {
+	ctx.Data["Title"] = ctx.Tr("admin.download_usage.title")
+	ctx.Data["PageIsAdminDownloadUsage"] = true
+
+	dayStr := ctx.FormString("day")
+	day := utcDay(time.Now())
+	if dayStr != "" {
+		t, err := time.Parse(time.DateOnly, dayStr)
+		if err != nil {
+			ctx.Flash.Error(ctx.Tr("admin.download_usage.invalid_day", dayStr), true)
+		} else {
+			day = utcDay(t)
+		}
+	}
+	kind := repo_model.DownloadUsageKind(ctx.FormString("kind"))
+	switch kind {
+	case "", repo_model.DownloadUsageUser, repo_model.DownloadUsageIP, repo_model.DownloadUsageOrg:
+	default:
+		kind = ""
+	}
+
+	page := max(ctx.FormInt("page"), 1)
+	opts := repo_model.FindDownloadUsageOptions{
+		ListOptions: db.ListOptions{Page: page, PageSize: setting.UI.Admin.UserPagingNum},
+		Kind:        kind,
+		Since:       day,
+		Until:       day,
+	}
+	usages, count, err := db.FindAndCount[repo_model.DownloadUsage](ctx, opts)
+	if err != nil {
+		ctx.ServerError("FindDownloadUsages", err)
+		return
+	}
+
+	var ownerIDs []int64
+	for _, u := range usages {
+		if u.OwnerID != 0 {
+			ownerIDs = append(ownerIDs, u.OwnerID)
+		}
+	}
+	owners, err := user_model.GetUsersMapByIDs(ctx, ownerIDs)
+	if err != nil {
+		ctx.ServerError("GetUsersMapByIDs", err)
+		return
+	}
+
+	s := download_service.Setting()
+	rows := make([]downloadUsageRow, 0, len(usages))
+	for _, u := range usages {
+		row := downloadUsageRow{DownloadUsage: u, Owner: owners[u.OwnerID], Quota: s.DailyQuotaPerUser}
+		if u.Kind == repo_model.DownloadUsageOrg {
+			row.Quota = s.DailyQuotaPerOrg
+		}
+		rows = append(rows, row)
+	}
+
+	ctx.Data["Day"] = day.FormatInLocation(time.DateOnly, time.UTC)
+	ctx.Data["Kind"] = kind
+	ctx.Data["Usages"] = rows
+	ctx.Data["Total"] = count
+	ctx.Data["DownloadUsageEnabled"] = DownloadUsageEnabled()
+
+	pager := context.NewPagination(int(count), opts.PageSize, opts.Page, 5)
+	pager.AddParamFromRequest(ctx.Req)
+	ctx.Data["Page"] = pager
+	ctx.HTML(http.StatusOK, tplAdminDownloadUsage)
+}
@@ -0,0 +1,41 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+// notifyRepositoryDownload queues the repository_download event for a successful archive or release asset
+// download, the download service looks up the webhooks in the background
+func notifyRepositoryDownload(ctx *context.Context, handler, format string, target *downloadAuditTarget) {
+	if !download_service.Setting().WebhookEnabled || ctx.Repo.Repository == nil {
+		return
+	}
+	switch handler {
+	case "folder", "folder_part", "tree", "archive", "release":
+	default:
+		return
+	}
+	if format == "json" || format == "csv" || ctx.Resp.WrittenStatus() >= http.StatusBadRequest || ctx.Resp.WrittenStatus() == http.StatusNotModified {
+		return
+	}
+
+	download := &download_service.Download{
+		RepoID: ctx.Repo.Repository.ID,
+		Kind:   handler,
+		Format: format,
+		Size:   int64(ctx.Resp.WrittenSize()),
+	}
+	if target != nil {
+		download.Ref, download.Commit, download.Path = target.Ref, target.Commit, target.Path
+	}
+	if ctx.Doer != nil {
+		download.DoerID = ctx.Doer.ID
+	}
+	download_service.QueueDownloadWebhook(download)
+}
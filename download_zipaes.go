@@ -0,0 +1,93 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"net/http"
+
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/templates"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	download_service "code.gitea.io/gitea/services/repository/download"
+)
+
+const tplDownloadPassword templates.TplName = "repo/download_password"
+
+const (
+	zipAESMinPasswordLength       = 8
+	zipAESGeneratedPasswordLength = 20
+
+	// zipAESPasswordSessionKey keeps a generated password until the archive is downloaded
+	zipAESPasswordSessionKey = "folder_download_aes_password"
+)
+
+// zipAESEnabled reports whether encrypted zip archives are offered for the folders of the current repository,
+// the instance must allow them and the repository must turn them on
+func zipAESEnabled(ctx *context.Context) bool {
+	if !download_service.Setting().ZipAESEnabled || ctx.Repo.Repository == nil {
+		return false
+	}
+	policy, err := getFolderDownloadPolicy(ctx, ctx.Repo.Repository.ID)
+	if err != nil {
+		log.Error("getFolderDownloadPolicy: %v", err)
+		return false
+	}
+	return policy.ZipAES
+}
+
+// resolveZipAESPassword returns the password of a "zip-aes" folder archive. A GET shows the password form,
+// which either posts a password chosen by the user or asks for one to be generated. A generated password
+// is shown once and kept in the session until the archive is downloaded with it.
+// It returns false if a page has been rendered instead.
+func resolveZipAESPassword(ctx *context.Context) (string, bool) {
+	if !zipAESEnabled(ctx) {
+		ctx.HTTPError(http.StatusBadRequest, "encrypted zip archives are disabled for this repository")
+		return "", false
+	}
+	if ctx.Req.Method != http.MethodPost {
+		renderZipAESPassword(ctx, "")
+		return "", false
+	}
+
+	switch {
+	case ctx.FormBool("use_generated"):
+		password, _ := ctx.Session.Get(zipAESPasswordSessionKey).(string)
+		if password == "" {
+			ctx.Flash.Error(ctx.Tr("repo.download_folder_aes_password_used"), true)
+			renderZipAESPassword(ctx, "")
+			return "", false
+		}
+		_ = ctx.Session.Delete(zipAESPasswordSessionKey)
+		return password, true
+	case ctx.FormBool("generate"):
+		password, err := util.CryptoRandomString(zipAESGeneratedPasswordLength)
+		if err != nil {
+			ctx.ServerError("CryptoRandomString", err)
+			return "", false
+		}
+		if err := ctx.Session.Set(zipAESPasswordSessionKey, password); err != nil {
+			ctx.ServerError("Session.Set", err)
+			return "", false
+		}
+		renderZipAESPassword(ctx, password)
+		return "", false
+	}
+
+	password := ctx.Req.PostFormValue("password")
+	if len(password) < zipAESMinPasswordLength {
+		ctx.Flash.Error(ctx.Tr("repo.download_folder_aes_password_too_short", zipAESMinPasswordLength), true)
+		renderZipAESPassword(ctx, "")
+		return "", false
+	}
+	return password, true
+}
+
+func renderZipAESPassword(ctx *context.Context, generated string) {
+	ctx.Data["Title"] = ctx.Tr("repo.download_folder_aes_title")
+	ctx.Data["GeneratedPassword"] = generated
+	ctx.Data["ArchiveLink"] = ctx.Req.URL.RequestURI()
+	ctx.Data["MinPasswordLength"] = zipAESMinPasswordLength
+	ctx.HTML(http.StatusOK, tplDownloadPassword)
+}
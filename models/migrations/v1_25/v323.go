@@ -0,0 +1,23 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package v1_25
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+func AddDownloadUsageTable(x *xorm.Engine) error {
+	type DownloadUsage struct {
+		ID      int64              `xorm:"pk autoincr"`
+		Day     timeutil.TimeStamp `xorm:"UNIQUE(s) INDEX NOT NULL"`
+		Kind    string             `xorm:"VARCHAR(8) UNIQUE(s) NOT NULL"`
+		OwnerID int64              `xorm:"UNIQUE(s) NOT NULL DEFAULT 0"`
+		IP      string             `xorm:"VARCHAR(64) UNIQUE(s) NOT NULL DEFAULT ''"`
+		Bytes   int64              `xorm:"NOT NULL DEFAULT 0"`
+	}
+
+	return x.Sync(new(DownloadUsage))
+}
@@ -0,0 +1,16 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package v1_25
+
+import (
+	"xorm.io/xorm"
+)
+
+func AddBrowseOnlyToTeam(x *xorm.Engine) error {
+	type Team struct {
+		BrowseOnly bool `xorm:"NOT NULL DEFAULT false"`
+	}
+
+	return x.Sync(new(Team))
+}
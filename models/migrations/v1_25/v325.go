@@ -0,0 +1,22 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package v1_25
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+func AddRepoArchivePolicyTable(x *xorm.Engine) error {
+	type RepoArchivePolicy struct {
+		ID                int64              `xorm:"pk autoincr"`
+		RepoID            int64              `xorm:"UNIQUE NOT NULL"`
+		AnonymousArchives string             `xorm:"VARCHAR(16) NOT NULL DEFAULT ''"`
+		BlockBots         int                `xorm:"NOT NULL DEFAULT 0"`
+		UpdatedUnix       timeutil.TimeStamp `xorm:"INDEX updated"`
+	}
+
+	return x.Sync(new(RepoArchivePolicy))
+}
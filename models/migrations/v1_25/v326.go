@@ -0,0 +1,16 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package v1_25
+
+import (
+	"xorm.io/xorm"
+)
+
+func AddZipAESToRepoArchivePolicy(x *xorm.Engine) error {
+	type RepoArchivePolicy struct {
+		ZipAES bool `xorm:"NOT NULL DEFAULT false"`
+	}
+
+	return x.Sync(new(RepoArchivePolicy))
+}
@@ -0,0 +1,25 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package v1_25
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+func AddRepoArchiveExportTable(x *xorm.Engine) error {
+	type RepoArchiveExport struct {
+		ID          int64              `xorm:"pk autoincr"`
+		RepoID      int64              `xorm:"UNIQUE NOT NULL"`
+		Enabled     bool               `xorm:"NOT NULL DEFAULT false"`
+		Folders     []string           `xorm:"TEXT JSON"`
+		Formats     []string           `xorm:"TEXT JSON"`
+		Tags        []string           `xorm:"TEXT JSON"`
+		Last        map[string]any     `xorm:"TEXT JSON"`
+		UpdatedUnix timeutil.TimeStamp `xorm:"updated"`
+	}
+
+	return x.Sync(new(RepoArchiveExport))
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package v1_25
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+	"xorm.io/xorm/schemas"
+)
+
+type downloadAudit struct {
+	ID          int64              `xorm:"pk autoincr"`
+	RepoID      int64              `xorm:"NOT NULL"`
+	RepoName    string             `xorm:"NOT NULL"`
+	UserID      int64              `xorm:"NOT NULL DEFAULT 0"`
+	UserName    string             `xorm:"NOT NULL DEFAULT ''"`
+	IP          string             `xorm:"VARCHAR(64) NOT NULL DEFAULT ''"`
+	Handler     string             `xorm:"VARCHAR(32) NOT NULL"`
+	Ref         string             `xorm:"TEXT"`
+	CommitID    string             `xorm:"VARCHAR(64)"`
+	Path        string             `xorm:"TEXT"`
+	Format      string             `xorm:"VARCHAR(16)"`
+	Bytes       int64              `xorm:"NOT NULL DEFAULT 0"`
+	Status      int                `xorm:"NOT NULL DEFAULT 0"`
+	CreatedUnix timeutil.TimeStamp `xorm:"INDEX NOT NULL"`
+}
+
+func (*downloadAudit) TableName() string {
+	return "download_audit"
+}
+
+// TableIndices implements xorm's TableIndices interface
+func (*downloadAudit) TableIndices() []*schemas.Index {
+	repoIndex := schemas.NewIndex("r_c", schemas.IndexType)
+	repoIndex.AddColumn("repo_id", "created_unix")
+	return []*schemas.Index{repoIndex}
+}
+
+func AddDownloadAuditTable(x *xorm.Engine) error {
+	return x.Sync(new(downloadAudit))
+}
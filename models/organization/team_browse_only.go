@@ -0,0 +1,31 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package organization
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+)
+
+// IsUserBrowseOnlyInRepo reports whether the user has access to the repository of the organization only
+// through browse-only teams. Such a user reads the code in the web UI, but cannot clone it or download
+// archives. Any other team or a direct collaboration lifts the restriction.
+func IsUserBrowseOnlyInRepo(ctx context.Context, orgID, userID, repoID int64) (bool, error) {
+	teams, err := GetUserRepoTeams(ctx, orgID, userID, repoID)
+	if err != nil || len(teams) == 0 {
+		return false, err
+	}
+	for _, team := range teams {
+		if !team.BrowseOnly || team.IsOwnerTeam() {
+			return false, nil
+		}
+	}
+
+	isCollaborator, err := db.GetEngine(ctx).Table("collaboration").Where("repo_id = ? AND user_id = ?", repoID, userID).Exist()
+	if err != nil {
+		return false, err
+	}
+	return !isCollaborator, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+)
+
+// ArchiveExport is the repository setting exporting folder archives to the instance export bucket when a tag
+// is pushed. The archive of folder "sdk/python" at tag "v1.2.0" of "acme/tools" is uploaded as
+// "acme/tools/v1.2.0/sdk/python.zip", the whole repository as "acme/tools/v1.2.0/tools.zip".
+type ArchiveExport struct {
+	ID      int64    `xorm:"pk autoincr"`
+	RepoID  int64    `xorm:"UNIQUE NOT NULL"`
+	Enabled bool     `xorm:"NOT NULL DEFAULT false"`
+	Folders []string `xorm:"TEXT JSON"`
+	Formats []string `xorm:"TEXT JSON"`
+	// Tags are the patterns of the exported tags, all tags are exported without patterns
+	Tags []string `xorm:"TEXT JSON"`
+
+	Last        *ArchiveExportResult `xorm:"TEXT JSON"`
+	UpdatedUnix timeutil.TimeStamp   `xorm:"updated"`
+}
+
+// ArchiveExportResult is the outcome of the latest export of a repository
+type ArchiveExportResult struct {
+	Tag      string    `json:"tag"`
+	Commit   string    `json:"commit"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts"`
+	Keys     []string  `json:"keys"`
+	Updated  time.Time `json:"updated"`
+	// NextAttempt is when a failed export is tried again, nil once it ran out of attempts
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+}
+
+// TableName sets the table name of the archive export settings
+func (*ArchiveExport) TableName() string {
+	return "repo_archive_export"
+}
+
+func init() {
+	db.RegisterModel(new(ArchiveExport))
+}
+
+// GetArchiveExport returns the export settings of a repository, disabled ones exporting zip archives if there are none
+func GetArchiveExport(ctx context.Context, repoID int64) (*ArchiveExport, error) {
+	export := &ArchiveExport{RepoID: repoID}
+	has, err := db.GetEngine(ctx).Where("repo_id = ?", repoID).Get(export)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		export.Formats = []string{"zip"}
+	}
+	return export, nil
+}
+
+// SaveArchiveExport inserts or updates the export settings and result of export.RepoID
+func SaveArchiveExport(ctx context.Context, export *ArchiveExport) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		exist, err := db.GetEngine(ctx).Where("repo_id = ?", export.RepoID).Exist(new(ArchiveExport))
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return db.Insert(ctx, export)
+		}
+		_, err = db.GetEngine(ctx).Where("repo_id = ?", export.RepoID).AllCols().Omit("id").Update(export)
+		return err
+	})
+}
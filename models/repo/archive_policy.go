@@ -0,0 +1,80 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+)
+
+// ArchiveBotPolicy overrides the instance setting refusing archives to crawlers
+type ArchiveBotPolicy int
+
+const (
+	ArchiveBotPolicyDefault ArchiveBotPolicy = iota // keep the instance setting
+	ArchiveBotPolicyBlock                           // refuse archives to crawlers
+	ArchiveBotPolicyAllow                           // serve archives to crawlers
+)
+
+// ArchivePolicy is the repository setting overriding the instance archive policy for folder downloads
+// and "/archive/*", the zero values keep the instance setting
+type ArchivePolicy struct {
+	ID     int64 `xorm:"pk autoincr"`
+	RepoID int64 `xorm:"UNIQUE NOT NULL"`
+	// AnonymousArchives is allow, signin or captcha
+	AnonymousArchives string           `xorm:"VARCHAR(16) NOT NULL DEFAULT ''"`
+	BlockBots         ArchiveBotPolicy `xorm:"NOT NULL DEFAULT 0"`
+	// ZipAES offers encrypted zip archives of the folders, when the instance allows them
+	ZipAES      bool               `xorm:"NOT NULL DEFAULT false"`
+	UpdatedUnix timeutil.TimeStamp `xorm:"INDEX updated"`
+}
+
+// TableName sets the table name of the archive policy
+func (*ArchivePolicy) TableName() string {
+	return "repo_archive_policy"
+}
+
+func init() {
+	db.RegisterModel(new(ArchivePolicy))
+}
+
+// Apply returns the anonymous archive policy and the bot blocking of the instance overridden by the repository
+func (p *ArchivePolicy) Apply(anonymous string, blockBots bool) (string, bool) {
+	if p.AnonymousArchives != "" {
+		anonymous = p.AnonymousArchives
+	}
+	switch p.BlockBots {
+	case ArchiveBotPolicyBlock:
+		blockBots = true
+	case ArchiveBotPolicyAllow:
+		blockBots = false
+	}
+	return anonymous, blockBots
+}
+
+// GetArchivePolicy returns the archive policy of a repository, a repository without one gets the zero policy
+func GetArchivePolicy(ctx context.Context, repoID int64) (*ArchivePolicy, error) {
+	policy := &ArchivePolicy{RepoID: repoID}
+	if _, err := db.GetEngine(ctx).Where("repo_id = ?", repoID).Get(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SaveArchivePolicy inserts or updates the archive policy of policy.RepoID
+func SaveArchivePolicy(ctx context.Context, policy *ArchivePolicy) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		exist, err := db.GetEngine(ctx).Where("repo_id = ?", policy.RepoID).Exist(new(ArchivePolicy))
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return db.Insert(ctx, policy)
+		}
+		_, err = db.GetEngine(ctx).Where("repo_id = ?", policy.RepoID).Cols("anonymous_archives", "block_bots", "zip_aes", "updated_unix").Update(policy)
+		return err
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"context"
+	"strings"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/builder"
+	"xorm.io/xorm/schemas"
+)
+
+// DownloadAudit records a raw, media or archive download. The names of the repository and the user are kept
+// as they were at the time of the download, the records outlive renamed and deleted repositories and users.
+type DownloadAudit struct {
+	ID          int64              `xorm:"pk autoincr"`
+	RepoID      int64              `xorm:"NOT NULL"`
+	RepoName    string             `xorm:"NOT NULL"` // the full name, "owner/repo"
+	UserID      int64              `xorm:"NOT NULL DEFAULT 0"`
+	UserName    string             `xorm:"NOT NULL DEFAULT ''"`
+	IP          string             `xorm:"VARCHAR(64) NOT NULL DEFAULT ''"`
+	Handler     string             `xorm:"VARCHAR(32) NOT NULL"`
+	Ref         string             `xorm:"TEXT"`
+	CommitID    string             `xorm:"VARCHAR(64)"`
+	Path        string             `xorm:"TEXT"`
+	Format      string             `xorm:"VARCHAR(16)"`
+	Bytes       int64              `xorm:"NOT NULL DEFAULT 0"`
+	Status      int                `xorm:"NOT NULL DEFAULT 0"`
+	CreatedUnix timeutil.TimeStamp `xorm:"INDEX NOT NULL"`
+}
+
+func init() {
+	db.RegisterModel(new(DownloadAudit))
+}
+
+// TableIndices implements xorm's TableIndices interface
+func (a *DownloadAudit) TableIndices() []*schemas.Index {
+	repoIndex := schemas.NewIndex("r_c", schemas.IndexType)
+	repoIndex.AddColumn("repo_id", "created_unix")
+	return []*schemas.Index{repoIndex}
+}
+
+// InsertDownloadAudit records a download
+func InsertDownloadAudit(ctx context.Context, audit *DownloadAudit) error {
+	return db.Insert(ctx, audit)
+}
+
+// FindDownloadAuditOptions selects download audit records, the zero fields match everything
+type FindDownloadAuditOptions struct {
+	db.ListOptions
+	RepoID int64
+	// RepoName and UserName are compared case-insensitively
+	RepoName string
+	UserName string
+	// Since and Until limit the time of the records, Until is exclusive
+	Since timeutil.TimeStamp
+	Until timeutil.TimeStamp
+}
+
+func (opts FindDownloadAuditOptions) ToConds() builder.Cond {
+	cond := builder.NewCond()
+	if opts.RepoID > 0 {
+		cond = cond.And(builder.Eq{"repo_id": opts.RepoID})
+	}
+	if opts.RepoName != "" {
+		cond = cond.And(builder.Expr("LOWER(repo_name) = ?", strings.ToLower(opts.RepoName)))
+	}
+	if opts.UserName != "" {
+		cond = cond.And(builder.Expr("LOWER(user_name) = ?", strings.ToLower(opts.UserName)))
+	}
+	if opts.Since > 0 {
+		cond = cond.And(builder.Gte{"created_unix": opts.Since})
+	}
+	if opts.Until > 0 {
+		cond = cond.And(builder.Lt{"created_unix": opts.Until})
+	}
+	return cond
+}
+
+// ToOrders lists the records newest first
+func (opts FindDownloadAuditOptions) ToOrders() string {
+	return "created_unix DESC, id DESC"
+}
+
+// DeleteDownloadAuditsBefore removes the records older than t
+func DeleteDownloadAuditsBefore(ctx context.Context, t timeutil.TimeStamp) error {
+	_, err := db.GetEngine(ctx).Where("created_unix < ?", t).Delete(new(DownloadAudit))
+	return err
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/builder"
+)
+
+// DownloadUsageKind tells whose downloads a DownloadUsage counts
+type DownloadUsageKind string
+
+const (
+	DownloadUsageUser DownloadUsageKind = "user" // a signed-in user
+	DownloadUsageIP   DownloadUsageKind = "ip"   // anonymous requests from an IP address
+	DownloadUsageOrg  DownloadUsageKind = "org"  // downloads from the repositories of an organization
+)
+
+// DownloadUsage is the number of bytes of raw files and archives served during a UTC day,
+// it is what the daily download quotas are checked against
+type DownloadUsage struct {
+	ID      int64              `xorm:"pk autoincr"`
+	Day     timeutil.TimeStamp `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	Kind    DownloadUsageKind  `xorm:"VARCHAR(8) UNIQUE(s) NOT NULL"`
+	OwnerID int64              `xorm:"UNIQUE(s) NOT NULL DEFAULT 0"` // the user or organization, 0 for anonymous requests
+	IP      string             `xorm:"VARCHAR(64) UNIQUE(s) NOT NULL DEFAULT ''"`
+	Bytes   int64              `xorm:"NOT NULL DEFAULT 0"`
+}
+
+func init() {
+	db.RegisterModel(new(DownloadUsage))
+}
+
+// DayString returns the UTC day of the usage as YYYY-MM-DD
+func (u *DownloadUsage) DayString() string {
+	return u.Day.FormatInLocation(time.DateOnly, time.UTC)
+}
+
+// IncreaseDownloadUsage adds n bytes to the usage of the given day, kind, owner and IP
+func IncreaseDownloadUsage(ctx context.Context, day timeutil.TimeStamp, kind DownloadUsageKind, ownerID int64, ip string, n int64) error {
+	e := db.GetEngine(ctx)
+	res, err := e.Exec("UPDATE download_usage SET bytes = bytes + ? WHERE day = ? AND kind = ? AND owner_id = ? AND ip = ?", n, day, kind, ownerID, ip)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// the first bytes of the day, another process may be inserting the same row concurrently
+	_, errIns := e.Insert(&DownloadUsage{Day: day, Kind: kind, OwnerID: ownerID, IP: ip, Bytes: n})
+	if errIns == nil {
+		return nil
+	}
+	res, err = e.Exec("UPDATE download_usage SET bytes = bytes + ? WHERE day = ? AND kind = ? AND owner_id = ? AND ip = ?", n, day, kind, ownerID, ip)
+	if err != nil {
+		return err
+	}
+	if affected, err = res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return errIns
+	}
+	return nil
+}
+
+// GetDownloadUsageBytes returns the bytes counted for the given day, kind, owner and IP
+func GetDownloadUsageBytes(ctx context.Context, day timeutil.TimeStamp, kind DownloadUsageKind, ownerID int64, ip string) (int64, error) {
+	var usage DownloadUsage
+	has, err := db.GetEngine(ctx).Where("day = ? AND kind = ? AND owner_id = ? AND ip = ?", day, kind, ownerID, ip).Get(&usage)
+	if err != nil || !has {
+		return 0, err
+	}
+	return usage.Bytes, nil
+}
+
+// FindDownloadUsageOptions filters the download usages of a report
+type FindDownloadUsageOptions struct {
+	db.ListOptions
+	Kind    DownloadUsageKind
+	OwnerID int64
+	Since   timeutil.TimeStamp
+	Until   timeutil.TimeStamp
+}
+
+func (opts FindDownloadUsageOptions) ToConds() builder.Cond {
+	cond := builder.NewCond()
+	if opts.Kind != "" {
+		cond = cond.And(builder.Eq{"kind": opts.Kind})
+	}
+	if opts.OwnerID != 0 {
+		cond = cond.And(builder.Eq{"owner_id": opts.OwnerID})
+	}
+	if opts.Since != 0 {
+		cond = cond.And(builder.Gte{"day": opts.Since})
+	}
+	if opts.Until != 0 {
+		cond = cond.And(builder.Lte{"day": opts.Until})
+	}
+	return cond
+}
+
+func (opts FindDownloadUsageOptions) ToOrders() string {
+	return "day DESC, bytes DESC, id ASC"
+}
+
+// DeleteDownloadUsagesBefore deletes the usages of the days before the given one
+func DeleteDownloadUsagesBefore(ctx context.Context, day timeutil.TimeStamp) error {
+	if day <= 0 {
+		return errors.New("invalid day")
+	}
+	_, err := db.GetEngine(ctx).Where("day < ?", day).Delete(new(DownloadUsage))
+	return err
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// UploadArchiveMaxArgs mirrors the limit git itself applies in upload-archive
+const UploadArchiveMaxArgs = 64
+
+// ErrUploadArchiveProtocol is returned for upload-archive requests which are not made of "argument" pkt-lines
+var ErrUploadArchiveProtocol = errors.New("invalid upload-archive request")
+
+// ReadUploadArchiveArguments reads the "argument" pkt-lines sent by an upload-archive client up to the flush
+// packet, nothing after it is read
+func ReadUploadArchiveArguments(r io.Reader) ([]string, error) {
+	var args []string
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, ErrUploadArchiveProtocol
+		}
+		length, err := strconv.ParseUint(string(header), 16, 16)
+		if err != nil {
+			return nil, ErrUploadArchiveProtocol
+		}
+		if length == 0 {
+			return args, nil
+		}
+		if length <= 4 {
+			return nil, ErrUploadArchiveProtocol
+		}
+		payload := make([]byte, length-4)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, ErrUploadArchiveProtocol
+		}
+		line := strings.TrimSuffix(string(payload), "\n")
+		arg, ok := strings.CutPrefix(line, "argument ")
+		if !ok {
+			return nil, ErrUploadArchiveProtocol
+		}
+		if len(args) >= UploadArchiveMaxArgs {
+			return nil, errors.New("too many arguments")
+		}
+		args = append(args, arg)
+	}
+}
+
+// WriteUploadArchiveArguments writes args as the request of an upload-archive client, ending with a flush packet
+func WriteUploadArchiveArguments(w io.Writer, args []string) error {
+	for _, arg := range args {
+		line := "argument " + arg
+		if _, err := fmt.Fprintf(w, "%04x%s\n", len(line)+5, line); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "0000")
+	return err
+}
+
+// ValidateUploadArchiveArguments applies the checks of a folder download to an upload-archive request: only
+// the format, compression level and prefix options are accepted, the tree-ish must resolve to a commit of repo
+// and every requested path must exist in it.
+func ValidateUploadArchiveArguments(repo *Repository, args []string) error {
+	var treeish string
+	var paths []string
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			continue
+		case treeish == "" && strings.HasPrefix(arg, "-"):
+			if !isAllowedUploadArchiveOption(arg) {
+				return fmt.Errorf("option '%s' is not allowed", arg)
+			}
+		case treeish == "":
+			treeish = arg
+		default:
+			p, err := cleanUploadArchivePath(arg)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, p)
+		}
+	}
+	if treeish == "" {
+		return errors.New("no tree-ish given")
+	}
+
+	commit, err := repo.GetCommit(treeish)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if _, err := commit.GetTreeEntryByPath(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isAllowedUploadArchiveOption(opt string) bool {
+	if len(opt) == 2 && opt[1] >= '0' && opt[1] <= '9' {
+		return true
+	}
+	if format, ok := strings.CutPrefix(opt, "--format="); ok {
+		switch format {
+		case "zip", "tar", "tar.gz", "tgz":
+			return true
+		}
+		return false
+	}
+	return strings.HasPrefix(opt, "--prefix=") && !strings.Contains(opt, "..")
+}
+
+// cleanUploadArchivePath refuses ".." elements and paths which git would take for an option
+func cleanUploadArchivePath(treePath string) (string, error) {
+	for _, part := range strings.Split(treePath, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid path '%s'", treePath)
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+treePath), "/")
+	if strings.HasPrefix(cleaned, "-") {
+		return "", fmt.Errorf("invalid path '%s'", treePath)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadUploadArchiveArguments(t *testing.T) {
+	args, err := ReadUploadArchiveArguments(strings.NewReader("001aargument --format=zip\n0014argument master\n0010argument foo0000trailing"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--format=zip", "master", "foo"}, args)
+
+	args, err = ReadUploadArchiveArguments(strings.NewReader("0000"))
+	require.NoError(t, err)
+	assert.Empty(t, args)
+
+	for name, input := range map[string]string{
+		"empty":            "",
+		"short header":     "00",
+		"not hex":          "zzzzargument master\n0000",
+		"no flush":         "0014argument master\n",
+		"truncated":        "0020argument master\n",
+		"header only":      "00040000",
+		"not an argument":  "000eversion 1\n0000",
+		"missing argument": "0012argumentmaster0000",
+	} {
+		_, err := ReadUploadArchiveArguments(strings.NewReader(input))
+		assert.ErrorIs(t, err, ErrUploadArchiveProtocol, name)
+	}
+
+	var tooMany strings.Builder
+	for range UploadArchiveMaxArgs + 1 {
+		tooMany.WriteString("000fargument x\n")
+	}
+	tooMany.WriteString("0000")
+	_, err = ReadUploadArchiveArguments(strings.NewReader(tooMany.String()))
+	assert.Error(t, err)
+}
+
+func TestWriteUploadArchiveArguments(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteUploadArchiveArguments(&buf, []string{"--format=zip", "master"}))
+	assert.Equal(t, "001aargument --format=zip\n0014argument master\n0000", buf.String())
+
+	args, err := ReadUploadArchiveArguments(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--format=zip", "master"}, args)
+}
+
+func TestValidateUploadArchiveArguments(t *testing.T) {
+	repo, err := OpenRepository(t.Context(), filepath.Join(testReposDir, "repo1_bare"))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	for _, args := range [][]string{
+		{"master"},
+		{"--format=zip", "-9", "--prefix=repo/", "master"},
+		{"--format=tar.gz", "master", "--", "foo", "file1.txt"},
+		{"master", "/foo/nar/"},
+		{"master", "."},
+	} {
+		assert.NoError(t, ValidateUploadArchiveArguments(repo, args), "%v", args)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"--format=zip"},
+		{"--remote=elsewhere", "master"},
+		{"--exec=sh", "master"},
+		{"--output=/tmp/x", "master"},
+		{"--format=7z", "master"},
+		{"--prefix=../", "master"},
+		{"master", "../foo"},
+		{"master", "foo/../../file1.txt"},
+		{"master", "-x"},
+	} {
+		assert.Error(t, ValidateUploadArchiveArguments(repo, args), "%v", args)
+	}
+
+	err = ValidateUploadArchiveArguments(repo, []string{"no-such-branch"})
+	assert.True(t, IsErrNotExist(err), "%v", err)
+	err = ValidateUploadArchiveArguments(repo, []string{"master", "no/such/path"})
+	assert.True(t, IsErrNotExist(err), "%v", err)
+}
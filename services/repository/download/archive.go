@@ -0,0 +1,774 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/container"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/git/attribute"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/util"
+)
+
+const (
+	ArchiveSymlinksKeep   = "keep"
+	ArchiveSymlinksFollow = "follow"
+	ArchiveSymlinksSkip   = "skip"
+
+	// maxLinkDepth limits symlink chains and nested followed directories
+	maxLinkDepth = 10
+
+	// ArchiveFormatZipAES is the format of zip archives encrypted with AES-256
+	ArchiveFormatZipAES = "zip-aes"
+
+	// ArchiveManifestName is written at the archive root when entries had to be left out
+	ArchiveManifestName = ".gitea-archive.json"
+
+	ArchiveEOLLF   = "lf"
+	ArchiveEOLCRLF = "crlf"
+
+	// ArchiveMtimeCommit dates every entry with the archived commit like "git archive",
+	// ArchiveMtimeFile with the last commit touching the file
+	ArchiveMtimeCommit = "commit"
+	ArchiveMtimeFile   = "file"
+
+	// binaryDetectionSize is how much of a blob git inspects for NUL bytes to detect binaries
+	binaryDetectionSize = 8000
+
+	// the attributes "git archive" applies: export-ignore leaves a path out, export-subst expands the
+	// "$Format:...$" placeholders of a file with the archived commit
+	attrExportIgnore = "export-ignore"
+	attrExportSubst  = "export-subst"
+)
+
+// ArchiveOptions controls how entries are written into a folder archive
+type ArchiveOptions struct {
+	Symlinks string
+	// EOL is the line ending text files are checked out with, empty keeps the content as stored
+	EOL string
+	// Mtime is ArchiveMtimeCommit or ArchiveMtimeFile
+	Mtime string
+	// Password encrypts the entries of "zip-aes" archives
+	Password string
+}
+
+// needsNativeArchiver reports whether the options go beyond what "git archive" can produce
+func (opts *ArchiveOptions) needsNativeArchiver(format string) bool {
+	return format == ArchiveFormatZipAES || opts.Symlinks != ArchiveSymlinksKeep || opts.EOL != "" ||
+		opts.Mtime == ArchiveMtimeFile
+}
+
+// CreateArchive writes the archive of treePath in commit, using "git archive" when possible.
+// Both keep the file modes of the tree, per-file mtimes and the other options need the in-process archiver.
+func CreateArchive(w io.Writer, gitRepo *git.Repository, commit *git.Commit, treePath, format string, opts *ArchiveOptions) error {
+	if !opts.needsNativeArchiver(format) {
+		return CreateGitArchive(w, gitRepo.Path, commit.ID.String(), treePath, format)
+	}
+	return WriteArchive(w, gitRepo, commit, treePath, format, opts)
+}
+
+// GetTree returns the tree of treePath in commit, an empty path is the root tree
+func GetTree(commit *git.Commit, treePath string) (*git.Tree, error) {
+	if treePath == "" {
+		return &commit.Tree, nil
+	}
+	return commit.SubTree(treePath)
+}
+
+// ArchiveExt returns the file extension for an archive format, zip is the default
+func ArchiveExt(format string) string {
+	switch strings.ToLower(format) {
+	case "tar":
+		return "tar"
+	case "tar.gz", "tgz", "gz":
+		return "tar.gz"
+	default:
+		return "zip"
+	}
+}
+
+// CleanTreePath normalizes a folder path taken from the request so it can be
+// passed to git safely. It returns an empty string for the repository root.
+func CleanTreePath(treePath string) (string, error) {
+	for _, part := range strings.Split(treePath, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid path '%s'", treePath)
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+treePath), "/")
+	if strings.HasPrefix(cleaned, "-") {
+		return "", fmt.Errorf("invalid path '%s'", treePath)
+	}
+	return cleaned, nil
+}
+
+// CreateGitArchive создает архив через git archive с буферизацией для больших файлов
+func CreateGitArchive(w io.Writer, repoPath string, commitHash string, treePath string, format string) error {
+	// Проверяем доступность git
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git not found: %v", err)
+	}
+
+	// Определяем формат
+	var formatArg string
+	switch strings.ToLower(format) {
+	case "tar":
+		formatArg = "tar"
+	case "tar.gz", "tgz", "gz":
+		formatArg = "tar.gz"
+	default:
+		formatArg = "zip"
+	}
+
+	// Создаем команду
+	args := []string{"archive", "--format=" + formatArg, commitHash}
+	if treePath != "" && treePath != "." {
+		args = append(args, "--", strings.TrimPrefix(treePath, "/"))
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoPath
+
+	// Получаем stdout команды
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Запускаем команду
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git: %v", err)
+	}
+
+	// Копируем данные с буферизацией
+	_, copyErr := io.Copy(w, stdout)
+
+	// Ждем завершения команды
+	waitErr := cmd.Wait()
+
+	// Обрабатываем ошибки
+	if copyErr != nil {
+		return fmt.Errorf("failed to copy archive data: %v", copyErr)
+	}
+
+	if waitErr != nil {
+		return fmt.Errorf("git archive failed: %v\nstderr: %s", waitErr, stderr.String())
+	}
+
+	return nil
+}
+
+// skippedSymlink records a symlink which was not written into the archive
+type skippedSymlink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
+
+// archiveManifest is stored as ArchiveManifestName inside the archive
+type archiveManifest struct {
+	Commit          string            `json:"commit"`
+	Path            string            `json:"path"`
+	Symlinks        string            `json:"symlinks"`
+	SkippedSymlinks []*skippedSymlink `json:"skipped_symlinks"`
+}
+
+// archiveEntryWriter hides the differences between the zip and tar formats
+type archiveEntryWriter interface {
+	WriteFile(name string, mode git.EntryMode, modTime time.Time, size int64, r io.Reader) error
+	WriteSymlink(name, target string, modTime time.Time) error
+	Close() error
+}
+
+func newArchiveEntryWriter(w io.Writer, format string, opts *ArchiveOptions) archiveEntryWriter {
+	if format == ArchiveFormatZipAES {
+		return &aesZipEntryWriter{zw: zip.NewWriter(w), password: opts.Password}
+	}
+	switch ArchiveExt(format) {
+	case "tar":
+		return &tarEntryWriter{tw: tar.NewWriter(w)}
+	case "tar.gz":
+		gz := gzip.NewWriter(w)
+		return &tarEntryWriter{tw: tar.NewWriter(gz), gz: gz}
+	default:
+		return &zipEntryWriter{zw: zip.NewWriter(w)}
+	}
+}
+
+// archiveFileMode converts a git tree entry mode to the permissions stored in archives
+func archiveFileMode(mode git.EntryMode) fs.FileMode {
+	if mode.IsExecutable() {
+		return 0o755
+	}
+	return 0o644
+}
+
+type zipEntryWriter struct {
+	zw *zip.Writer
+}
+
+// WriteFile stores the Unix mode in the external attributes, so unzip restores the executable bit
+func (w *zipEntryWriter) WriteFile(name string, mode git.EntryMode, modTime time.Time, _ int64, r io.Reader) error {
+	fh := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	fh.SetMode(archiveFileMode(mode))
+	dst, err := w.zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+func (w *zipEntryWriter) WriteSymlink(name, target string, modTime time.Time) error {
+	fh := &zip.FileHeader{Name: name, Method: zip.Store, Modified: modTime}
+	fh.SetMode(fs.ModeSymlink | 0o777)
+	dst, err := w.zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(dst, target)
+	return err
+}
+
+func (w *zipEntryWriter) Close() error {
+	return w.zw.Close()
+}
+
+type tarEntryWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (w *tarEntryWriter) WriteFile(name string, mode git.EntryMode, modTime time.Time, size int64, r io.Reader) error {
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(archiveFileMode(mode)),
+		Size:     size,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarEntryWriter) WriteSymlink(name, target string, modTime time.Time) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     name,
+		Linkname: target,
+		Mode:     0o777,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+}
+
+func (w *tarEntryWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// archiver walks a tree and writes its entries according to the archive options
+type archiver struct {
+	gitRepo  *git.Repository
+	commit   *git.Commit
+	opts     *ArchiveOptions
+	w        archiveEntryWriter
+	attrs    *attribute.BatchChecker
+	mtimes   map[string]time.Time
+	manifest *archiveManifest
+	// ignored lists the directories left out by export-ignore, with a trailing slash
+	ignored []string
+	// substs caches the expansion of each "$Format:...$" placeholder
+	substs map[string][]byte
+	// files and size count what was written, followed symlinks can make an archive much larger than its tree
+	files int
+	size  int64
+}
+
+// ErrArchiveTooLarge is returned when an archive exceeds ARCHIVE_MAX_FILES or ARCHIVE_MAX_SIZE
+var ErrArchiveTooLarge = errors.New("folder archive is too large")
+
+// WriteArchive builds the archive in-process, entries keep their full path in the repository like "git archive" does
+func WriteArchive(w io.Writer, gitRepo *git.Repository, commit *git.Commit, treePath, format string, opts *ArchiveOptions) error {
+	tree, err := GetTree(commit, treePath)
+	if err != nil {
+		return err
+	}
+	entries, err := tree.ListEntriesRecursiveWithSize()
+	if err != nil {
+		return err
+	}
+
+	var mtimes map[string]time.Time
+	if opts.Mtime == ArchiveMtimeFile {
+		files := make(container.Set[string], len(entries))
+		for _, entry := range entries {
+			if !entry.IsDir() && !entry.IsSubModule() {
+				files.Add(path.Join(treePath, entry.Name()))
+			}
+		}
+		if mtimes, err = cachedLastCommitTimes(gitRepo, commit.ID.String(), treePath, files); err != nil {
+			return err
+		}
+	}
+
+	a := &archiver{
+		gitRepo: gitRepo,
+		commit:  commit,
+		opts:    opts,
+		w:       newArchiveEntryWriter(w, format, opts),
+		mtimes:  mtimes,
+		manifest: &archiveManifest{
+			Commit:   commit.ID.String(),
+			Path:     treePath,
+			Symlinks: opts.Symlinks,
+		},
+		substs: make(map[string][]byte),
+	}
+	// the attributes are read from the archived commit, like "git archive" does
+	a.attrs, err = attribute.NewBatchChecker(gitRepo, commit.ID.String(), []string{"text", "eol", attrExportIgnore, attrExportSubst})
+	if err != nil {
+		return err
+	}
+	defer a.attrs.Close()
+	if err := a.addEntries(entries, treePath, treePath, []string{treePath}); err != nil {
+		return err
+	}
+	if len(a.manifest.SkippedSymlinks) > 0 {
+		data, err := json.MarshalIndent(a.manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := a.w.WriteFile(path.Join(treePath, ArchiveManifestName), git.EntryModeBlob, commit.Committer.When, int64(len(data)), strings.NewReader(string(data))); err != nil {
+			return err
+		}
+	}
+	return a.w.Close()
+}
+
+// modTime returns the time of the last commit touching the file at src, or the archived commit's time
+func (a *archiver) modTime(src string) time.Time {
+	if t, ok := a.mtimes[src]; ok {
+		return t
+	}
+	return a.commit.Committer.When
+}
+
+// addTree writes all entries of tree, srcPath is its location in the repository and dstPath its location in the archive.
+// followed lists the directories of the repository whose content is being written, the archived folder first and then
+// the target of each directory symlink followed to reach srcPath.
+func (a *archiver) addTree(tree *git.Tree, srcPath, dstPath string, followed []string) error {
+	entries, err := tree.ListEntriesRecursiveWithSize()
+	if err != nil {
+		return err
+	}
+	return a.addEntries(entries, srcPath, dstPath, followed)
+}
+
+// addEntries writes entries listed recursively from the tree at srcPath, a directory is listed before its content
+func (a *archiver) addEntries(entries git.Entries, srcPath, dstPath string, followed []string) error {
+	for _, entry := range entries {
+		src, dst := path.Join(srcPath, entry.Name()), path.Join(dstPath, entry.Name())
+		if slices.ContainsFunc(a.ignored, func(dir string) bool { return strings.HasPrefix(src, dir) }) {
+			continue
+		}
+		attrs, err := a.attrs.CheckPath(util.Iif(entry.IsDir(), src+"/", src))
+		if err != nil {
+			return err
+		}
+		if attrs.Get(attrExportIgnore).ToBool().Value() {
+			if entry.IsDir() {
+				a.ignored = append(a.ignored, src+"/")
+			}
+			continue
+		}
+
+		switch {
+		case entry.IsDir(), entry.IsSubModule():
+			continue
+		case entry.IsLink():
+			err = a.addSymlink(entry, src, dst, followed)
+		default:
+			err = a.addBlob(entry, src, dst, attrs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count accounts for an entry of size bytes and fails once the archive exceeds the configured limits
+func (a *archiver) count(size int64) error {
+	a.files++
+	a.size += size
+	s := Setting()
+	if (s.ArchiveMaxFiles >= 0 && a.files > s.ArchiveMaxFiles) || (s.ArchiveMaxSize >= 0 && a.size > s.ArchiveMaxSize) {
+		return ErrArchiveTooLarge
+	}
+	return nil
+}
+
+// addBlob writes the blob at src in the repository as dst in the archive
+func (a *archiver) addBlob(entry *git.TreeEntry, src, dst string, attrs *attribute.Attributes) error {
+	if err := a.count(entry.Blob().Size()); err != nil {
+		return err
+	}
+	convert, autoDetect := a.checkoutWithCRLF(attrs)
+	if attrs.Get(attrExportSubst).ToBool().Value() {
+		return a.addBlobWithSubst(entry, dst, a.modTime(src), convert, autoDetect)
+	}
+	if convert {
+		return a.addBlobWithCRLF(entry, dst, a.modTime(src), autoDetect)
+	}
+
+	dataRc, err := entry.Blob().DataAsync()
+	if err != nil {
+		return err
+	}
+	defer dataRc.Close()
+	return a.w.WriteFile(dst, entry.Mode(), a.modTime(src), entry.Blob().Size(), dataRc)
+}
+
+// checkoutWithCRLF applies the "text" and "eol" attributes the way "git checkout" does.
+// autoDetect means the file must be left untouched if it turns out to be binary.
+func (a *archiver) checkoutWithCRLF(attrs *attribute.Attributes) (convert, autoDetect bool) {
+	if a.opts.EOL == "" {
+		return false, false
+	}
+	eolAttr := string(attrs.Get("eol"))
+	hasEOLAttr := eolAttr == ArchiveEOLLF || eolAttr == ArchiveEOLCRLF
+
+	eol := a.opts.EOL
+	if hasEOLAttr {
+		eol = eolAttr
+	}
+	if eol != ArchiveEOLCRLF {
+		// blobs are stored with LF, checking out with LF keeps them as they are
+		return false, false
+	}
+
+	switch attrs.Get("text") {
+	case "set", "true":
+		return true, false
+	case "unset", "false":
+		return false, false
+	case "auto":
+		return true, true
+	}
+	// an explicit eol marks the file as text, otherwise behave like core.autocrlf=true
+	return true, !hasEOLAttr
+}
+
+// addBlobWithCRLF writes a text blob with LF converted to CRLF, the first pass computes the converted size
+func (a *archiver) addBlobWithCRLF(entry *git.TreeEntry, dst string, modTime time.Time, autoDetect bool) error {
+	dataRc, err := entry.Blob().DataAsync()
+	if err != nil {
+		return err
+	}
+	lonelyLF, binary, hasCRLF, err := scanLineEndings(dataRc)
+	dataRc.Close()
+	if err != nil {
+		return err
+	}
+
+	dataRc, err = entry.Blob().DataAsync()
+	if err != nil {
+		return err
+	}
+	defer dataRc.Close()
+
+	// like git, text=auto never touches binaries or files which already contain CRLF
+	if lonelyLF == 0 || (autoDetect && (binary || hasCRLF)) {
+		return a.w.WriteFile(dst, entry.Mode(), modTime, entry.Blob().Size(), dataRc)
+	}
+	return a.w.WriteFile(dst, entry.Mode(), modTime, entry.Blob().Size()+lonelyLF, &crlfReader{r: bufio.NewReader(dataRc)})
+}
+
+// exportSubstFormatRe matches the placeholders expanded in files with the export-subst attribute
+var exportSubstFormatRe = regexp.MustCompile(`\$Format:([^$]*)\$`)
+
+// addBlobWithSubst writes a blob with the export-subst attribute. Like "git archive", the placeholders are
+// expanded after the line endings are converted. The blob is read in memory, its final size is only known then.
+func (a *archiver) addBlobWithSubst(entry *git.TreeEntry, dst string, modTime time.Time, convert, autoDetect bool) error {
+	content, err := entry.Blob().GetBlobBytes(entry.Blob().Size() + 1)
+	if err != nil {
+		return err
+	}
+	if convert {
+		lonelyLF, binary, hasCRLF, _ := scanLineEndings(bytes.NewReader(content))
+		if lonelyLF > 0 && !(autoDetect && (binary || hasCRLF)) {
+			if content, err = io.ReadAll(&crlfReader{r: bufio.NewReader(bytes.NewReader(content))}); err != nil {
+				return err
+			}
+		}
+	}
+
+	var substErr error
+	content = exportSubstFormatRe.ReplaceAllFunc(content, func(placeholder []byte) []byte {
+		expanded, err := a.expandSubst(string(exportSubstFormatRe.FindSubmatch(placeholder)[1]))
+		if err != nil {
+			substErr = err
+		}
+		return expanded
+	})
+	if substErr != nil {
+		return substErr
+	}
+	return a.w.WriteFile(dst, entry.Mode(), modTime, int64(len(content)), bytes.NewReader(content))
+}
+
+// expandSubst formats the archived commit with the pretty format of a "$Format:...$" placeholder
+func (a *archiver) expandSubst(format string) ([]byte, error) {
+	if expanded, ok := a.substs[format]; ok {
+		return expanded, nil
+	}
+	cmd := exec.CommandContext(a.gitRepo.Ctx, "git", "-c", "log.showSignature=false", "log", "-1", "--format=format:"+format, a.commit.ID.String(), "--")
+	cmd.Dir = a.gitRepo.Path
+	expanded, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("expand $Format:%s$: %w", format, err)
+	}
+	a.substs[format] = expanded
+	return expanded, nil
+}
+
+// scanLineEndings counts LFs not preceded by CR and applies git's binary heuristic
+func scanLineEndings(r io.Reader) (lonelyLF int64, binary, hasCRLF bool, err error) {
+	br := bufio.NewReader(r)
+	var prev byte
+	for n := 0; ; n++ {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return lonelyLF, binary, hasCRLF, nil
+		} else if err != nil {
+			return 0, false, false, err
+		}
+		switch {
+		case b == 0 && n < binaryDetectionSize:
+			binary = true
+		case b == '\n' && prev == '\r':
+			hasCRLF = true
+		case b == '\n':
+			lonelyLF++
+		}
+		prev = b
+	}
+}
+
+// crlfReader converts LF which is not preceded by CR to CRLF
+type crlfReader struct {
+	r         *bufio.Reader
+	prevCR    bool
+	pendingLF bool
+}
+
+func (c *crlfReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if c.pendingLF {
+			p[n] = '\n'
+			n++
+			c.pendingLF, c.prevCR = false, false
+			continue
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b == '\n' && !c.prevCR {
+			p[n] = '\r'
+			n++
+			c.pendingLF = true
+			continue
+		}
+		p[n] = b
+		n++
+		c.prevCR = b == '\r'
+	}
+	return n, nil
+}
+
+func (a *archiver) skipSymlink(dst, target, reason string) {
+	a.manifest.SkippedSymlinks = append(a.manifest.SkippedSymlinks, &skippedSymlink{Path: dst, Target: target, Reason: reason})
+}
+
+func (a *archiver) addSymlink(entry *git.TreeEntry, src, dst string, followed []string) error {
+	if a.opts.Symlinks != ArchiveSymlinksFollow {
+		target, err := entry.Blob().GetBlobContent(4096)
+		if err != nil {
+			return err
+		}
+		if a.opts.Symlinks == ArchiveSymlinksSkip {
+			a.skipSymlink(dst, target, "skipped")
+			return nil
+		}
+		if err := a.count(int64(len(target))); err != nil {
+			return err
+		}
+		return a.w.WriteSymlink(dst, target, a.modTime(src))
+	}
+
+	target, targetPath, targetEntry, reason := a.resolveSymlink(entry, src)
+	switch {
+	case reason != "":
+		a.skipSymlink(dst, target, reason)
+	case targetEntry.IsSubModule():
+		a.skipSymlink(dst, target, "target is a submodule")
+	case targetEntry.IsDir():
+		// following a directory which is already being written, or which contains one of them, never ends:
+		// "A/x -> ../B" next to "B/y -> ../A" would nest A and B into each other
+		if targetPath == "" || strings.HasPrefix(src, targetPath+"/") || slices.ContainsFunc(followed, func(dir string) bool {
+			return dir == targetPath || strings.HasPrefix(dir, targetPath+"/")
+		}) {
+			a.skipSymlink(dst, target, "symlink loop")
+			return nil
+		}
+		if len(followed) > maxLinkDepth {
+			a.skipSymlink(dst, target, "too many levels of symbolic links")
+			return nil
+		}
+		return a.addTree(targetEntry.Tree(), targetPath, dst, append(slices.Clip(followed), targetPath))
+	default:
+		attrs, err := a.attrs.CheckPath(targetPath)
+		if err != nil {
+			return err
+		}
+		return a.addBlob(targetEntry, targetPath, dst, attrs)
+	}
+	return nil
+}
+
+// resolveSymlink follows a symlink chain inside the commit, a non-empty reason means it can't be followed
+func (a *archiver) resolveSymlink(entry *git.TreeEntry, fullPath string) (target, targetPath string, targetEntry *git.TreeEntry, reason string) {
+	for range maxLinkDepth {
+		res, err := git.EntryFollowLink(a.commit, fullPath, entry)
+		if res == nil {
+			return target, "", nil, "unreadable symlink"
+		}
+		if target == "" {
+			target = res.SymlinkContent
+		}
+		if strings.HasPrefix(res.SymlinkContent, "/") {
+			return target, "", nil, "absolute target"
+		}
+		if joined := path.Join(path.Dir(fullPath), res.SymlinkContent); joined == ".." || strings.HasPrefix(joined, "../") {
+			return target, "", nil, "target outside repository"
+		}
+		if err != nil {
+			return target, "", nil, "target not found"
+		}
+		entry, fullPath = res.TargetEntry, res.TargetFullPath
+		if !entry.IsLink() {
+			return target, fullPath, entry, ""
+		}
+	}
+	return target, "", nil, "too many levels of symbolic links"
+}
+
+// cachedLastCommitTimes returns lastCommitTimes of the files below treePath, kept in the cache as the
+// history of a commit never changes
+func cachedLastCommitTimes(gitRepo *git.Repository, commitID, treePath string, files container.Set[string]) (map[string]time.Time, error) {
+	pathHash := sha256.Sum256([]byte(treePath))
+	data, err := cache.GetString("folder_archive_mtimes_"+commitID+"_"+hex.EncodeToString(pathHash[:8]), func() (string, error) {
+		mtimes, err := lastCommitTimes(gitRepo.Ctx, gitRepo.Path, commitID, treePath, files, Setting().FileMtimeMaxCommits)
+		if err != nil {
+			return "", err
+		}
+		unix := make(map[string]int64, len(mtimes))
+		for p, t := range mtimes {
+			unix[p] = t.Unix()
+		}
+		data, err := json.Marshal(unix)
+		return string(data), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var unix map[string]int64
+	if err = json.Unmarshal([]byte(data), &unix); err != nil {
+		return nil, err
+	}
+	mtimes := make(map[string]time.Time, len(unix))
+	for p, sec := range unix {
+		mtimes[p] = time.Unix(sec, 0)
+	}
+	return mtimes, nil
+}
+
+// lastCommitTimes returns the time of the last commit touching each of files, which are full paths below
+// treePath. Paths of the history which are not in files, like deleted or renamed ones, are ignored.
+// It stops once all files are known or maxCommits commits were read.
+func lastCommitTimes(ctx context.Context, repoPath, commitID, treePath string, files container.Set[string], maxCommits int) (map[string]time.Time, error) {
+	args := []string{"-c", "core.quotePath=false", "log", "--format=%x00%ct", "--name-only", "--no-renames"}
+	if maxCommits > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(maxCommits))
+	}
+	args = append(args, commitID, "--")
+	if treePath != "" {
+		args = append(args, treePath)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// git log is stopped early once all files are found
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	mtimes := make(map[string]time.Time, len(files))
+	var current time.Time
+	scanner := bufio.NewScanner(stdout)
+	for len(mtimes) < len(files) && scanner.Scan() {
+		line := scanner.Text()
+		if ts, ok := strings.CutPrefix(line, "\x00"); ok {
+			sec, _ := strconv.ParseInt(ts, 10, 64)
+			current = time.Unix(sec, 0)
+			continue
+		}
+		if _, ok := mtimes[line]; !ok && files.Contains(line) {
+			mtimes[line] = current
+		}
+	}
+	return mtimes, scanner.Err()
+}
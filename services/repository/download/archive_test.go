@@ -0,0 +1,118 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSymlinkLoopRepo commits two directories whose symlinks point at each other
+func newSymlinkLoopRepo(t *testing.T) (*git.Repository, *git.Commit) {
+	dir := t.TempDir()
+	for _, d := range []string{"A", "B"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, d), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "A", "f"), []byte("a\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "B", "g"), []byte("b\n"), 0o644))
+	require.NoError(t, os.Symlink("../B", filepath.Join(dir, "A", "x")))
+	require.NoError(t, os.Symlink("../A", filepath.Join(dir, "B", "y")))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "loop"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	gitRepo, err := git.OpenRepository(t.Context(), dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { gitRepo.Close() })
+	commit, err := gitRepo.GetCommit("HEAD")
+	require.NoError(t, err)
+	return gitRepo, commit
+}
+
+func tarEntryNames(t *testing.T, data []byte) []string {
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestWriteFolderArchiveSymlinkLoop(t *testing.T) {
+	gitRepo, commit := newSymlinkLoopRepo(t)
+	opts := &ArchiveOptions{Symlinks: ArchiveSymlinksFollow, Mtime: ArchiveMtimeCommit}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, gitRepo, commit, "", "tar", opts))
+	assert.ElementsMatch(t, []string{
+		"A/f", "A/x/g", "A/x/y/f",
+		"B/g", "B/y/f", "B/y/x/g",
+		ArchiveManifestName,
+	}, tarEntryNames(t, buf.Bytes()))
+
+	buf.Reset()
+	require.NoError(t, WriteArchive(&buf, gitRepo, commit, "A", "tar", opts))
+	assert.ElementsMatch(t, []string{"A/f", "A/x/g", "A/" + ArchiveManifestName}, tarEntryNames(t, buf.Bytes()))
+}
+
+func TestWriteFolderArchiveLimits(t *testing.T) {
+	gitRepo, commit := newSymlinkLoopRepo(t)
+	opts := &ArchiveOptions{Symlinks: ArchiveSymlinksFollow, Mtime: ArchiveMtimeCommit}
+
+	defer test.MockVariableValue(&Setting().ArchiveMaxFiles, 3)()
+	assert.ErrorIs(t, WriteArchive(io.Discard, gitRepo, commit, "", "tar", opts), ErrArchiveTooLarge)
+
+	defer test.MockVariableValue(&Setting().ArchiveMaxFiles, -1)()
+	defer test.MockVariableValue(&Setting().ArchiveMaxSize, int64(5))()
+	assert.ErrorIs(t, WriteArchive(io.Discard, gitRepo, commit, "", "tar", opts), ErrArchiveTooLarge)
+
+	defer test.MockVariableValue(&Setting().ArchiveMaxSize, int64(-1))()
+	assert.NoError(t, WriteArchive(io.Discard, gitRepo, commit, "", "tar", opts))
+}
+
+func TestCRLFReader(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                 "",
+		"a\nb\n":           "a\r\nb\r\n",
+		"a\r\nb\r\n":       "a\r\nb\r\n",
+		"mixed\r\nlf\nend": "mixed\r\nlf\r\nend",
+		"\n\n":             "\r\n\r\n",
+		"cr\ronly\r":       "cr\ronly\r",
+		"cr\r\r\n":         "cr\r\r\n",
+	} {
+		out, err := io.ReadAll(&crlfReader{r: bufio.NewReader(strings.NewReader(input))})
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(out), "%q", input)
+
+		// the LF of a converted line ending is kept for the next read when the buffer is full
+		out, err = io.ReadAll(iotest.OneByteReader(&crlfReader{r: bufio.NewReader(strings.NewReader(input))}))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(out), "%q one byte at a time", input)
+	}
+}
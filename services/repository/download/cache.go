@@ -0,0 +1,300 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	system_model "code.gitea.io/gitea/models/system"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/globallock"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/storage"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// cacheDir is the prefix of cached folder archives in the repository archive storage
+	cacheDir = "folder-archives"
+	// cacheIndexName describes a cached archive, it is written last so its presence marks a complete archive
+	cacheIndexName = "index.json"
+)
+
+// CachedArchive is a folder archive stored in the repository archive storage, in one or several parts
+type CachedArchive struct {
+	Key     string               `json:"key"`
+	Commit  string               `json:"commit"`
+	Path    string               `json:"path"`
+	Name    string               `json:"name"`
+	Format  string               `json:"format"`
+	Size    int64                `json:"size"`
+	SHA256  string               `json:"sha256"`
+	Parts   []*CachedArchivePart `json:"parts"`
+	Created time.Time            `json:"created"`
+}
+
+type CachedArchivePart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ArchiveCacheKey identifies an archive by everything which changes its content
+func ArchiveCacheKey(commitID, treePath, format string, opts *ArchiveOptions, partSize int64) string {
+	h := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%d", commitID, treePath, ArchiveExt(format), opts.Symlinks, opts.EOL, opts.Mtime, partSize))
+	return hex.EncodeToString(h[:])
+}
+
+func ArchiveCachePath(repoID int64, key string, elems ...string) string {
+	return path.Join(append([]string{cacheDir, strconv.FormatInt(repoID, 10), key}, elems...)...)
+}
+
+// ReadCachedArchive returns the cached archive of key, an fs.ErrNotExist error if there is none
+func ReadCachedArchive(repoID int64, key string) (*CachedArchive, error) {
+	obj, err := storage.RepoArchives.Open(ArchiveCachePath(repoID, key, cacheIndexName))
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	a := &CachedArchive{}
+	if err := json.NewDecoder(obj).Decode(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetOrCreateCachedArchive returns the cached archive of treePath, generating it on first use.
+// A partSize above 0 splits the archive into "name.ext.001", "name.ext.002", ... parts of that size.
+func GetOrCreateCachedArchive(ctx context.Context, repo *repo_model.Repository, gitRepo *git.Repository, commit *git.Commit, treePath, baseName, format string, opts *ArchiveOptions, partSize int64) (*CachedArchive, error) {
+	key := ArchiveCacheKey(commit.ID.String(), treePath, format, opts, partSize)
+	releaser, err := globallock.Lock(ctx, "folder_archive_"+key)
+	if err != nil {
+		return nil, err
+	}
+	defer releaser()
+
+	a, err := ReadCachedArchive(repo.ID, key)
+	if err == nil {
+		ObserveCacheLookup("folder_archive", true)
+		return a, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	ObserveCacheLookup("folder_archive", false)
+
+	a = &CachedArchive{
+		Key:     key,
+		Commit:  commit.ID.String(),
+		Path:    treePath,
+		Name:    baseName + "." + ArchiveExt(format),
+		Format:  ArchiveExt(format),
+		Created: time.Now().UTC(),
+	}
+	w := &splitArchiveWriter{
+		partSize: partSize,
+		whole:    sha256.New(),
+		partName: func(i int) string {
+			if partSize <= 0 {
+				return a.Name
+			}
+			return fmt.Sprintf("%s.%03d", a.Name, i)
+		},
+		upload: func(name string, r io.Reader, size int64) error {
+			_, err := storage.RepoArchives.Save(ArchiveCachePath(repo.ID, key, name), r, size)
+			return err
+		},
+	}
+	defer w.cleanup()
+
+	done := ObserveArchiveGeneration(a.Format)
+	err = CreateArchive(w, gitRepo, commit, treePath, format, opts)
+	done()
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		removeCachedArchive(repo.ID, key)
+		return nil, err
+	}
+	a.Parts, a.Size, a.SHA256 = w.parts, w.size, hex.EncodeToString(w.whole.Sum(nil))
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := storage.RepoArchives.Save(ArchiveCachePath(repo.ID, key, cacheIndexName), strings.NewReader(string(data)), int64(len(data))); err != nil {
+		return nil, err
+	}
+	pruneArchiveCache(repo.ID)
+	return a, nil
+}
+
+// removeCachedArchive deletes all objects of a cached archive
+func removeCachedArchive(repoID int64, key string) {
+	if err := storage.RepoArchives.IterateObjects(ArchiveCachePath(repoID, key), func(p string, _ storage.Object) error {
+		return storage.RepoArchives.Delete(p)
+	}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("removeCachedArchive: %v", err)
+	}
+}
+
+// DeleteRepositoryArchives removes everything kept for the folder downloads of a deleted repository:
+// the cached archives with their split statuses and the pre-build statuses
+func DeleteRepositoryArchives(ctx context.Context, repoID int64) {
+	if err := storage.RepoArchives.IterateObjects(path.Join(cacheDir, strconv.FormatInt(repoID, 10)), func(p string, _ storage.Object) error {
+		system_model.RemoveStorageWithNotice(ctx, storage.RepoArchives, "Delete folder archive file", p)
+		return nil
+	}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("DeleteRepositoryArchives: %v", err)
+	}
+}
+
+// pruneArchiveCache removes the cached archives of a repository older than ArchiveCacheTTL
+func pruneArchiveCache(repoID int64) {
+	ttl := Setting().ArchiveCacheTTL
+	if ttl <= 0 {
+		return
+	}
+	var expired []string
+	if err := storage.RepoArchives.IterateObjects(path.Join(cacheDir, strconv.FormatInt(repoID, 10)), func(p string, obj storage.Object) error {
+		if path.Base(p) != cacheIndexName {
+			return nil
+		}
+		a := &CachedArchive{}
+		if err := json.NewDecoder(obj).Decode(a); err != nil || time.Since(a.Created) > ttl {
+			expired = append(expired, path.Base(path.Dir(p)))
+		}
+		return nil
+	}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("pruneArchiveCache: %v", err)
+		return
+	}
+	for _, key := range expired {
+		removeCachedArchive(repoID, key)
+	}
+}
+
+// splitArchiveWriter buffers an archive in temporary files of at most partSize bytes
+// and uploads each part once it is complete. A partSize of 0 writes a single part.
+type splitArchiveWriter struct {
+	partSize int64
+	partName func(i int) string
+	upload   func(name string, r io.Reader, size int64) error
+
+	whole hash.Hash
+	size  int64
+	parts []*CachedArchivePart
+
+	cur        *os.File
+	curCleanup func()
+	curHash    hash.Hash
+	curSize    int64
+}
+
+func (w *splitArchiveWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.cur == nil {
+			f, cleanup, err := setting.AppDataTempDir("folder-archives").CreateTempFileRandom("part")
+			if err != nil {
+				return written, err
+			}
+			w.cur, w.curCleanup, w.curHash, w.curSize = f, cleanup, sha256.New(), 0
+		}
+		chunk := p
+		if w.partSize > 0 {
+			chunk = p[:min(int64(len(p)), w.partSize-w.curSize)]
+		}
+		n, err := w.cur.Write(chunk)
+		w.curHash.Write(chunk[:n])
+		w.whole.Write(chunk[:n])
+		w.curSize += int64(n)
+		w.size += int64(n)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		if w.partSize > 0 && w.curSize == w.partSize {
+			if err := w.finishPart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// finishPart uploads the current part and removes its temporary file
+func (w *splitArchiveWriter) finishPart() error {
+	defer w.cleanup()
+	part := &CachedArchivePart{
+		Name:   w.partName(len(w.parts) + 1),
+		Size:   w.curSize,
+		SHA256: hex.EncodeToString(w.curHash.Sum(nil)),
+	}
+	if _, err := w.cur.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	// hide Close from storages which close the reader after saving
+	if err := w.upload(part.Name, struct{ io.Reader }{w.cur}, w.curSize); err != nil {
+		return err
+	}
+	w.parts = append(w.parts, part)
+	return nil
+}
+
+// Close uploads the last part
+func (w *splitArchiveWriter) Close() error {
+	if w.cur == nil {
+		return nil
+	}
+	return w.finishPart()
+}
+
+func (w *splitArchiveWriter) cleanup() {
+	if w.cur != nil {
+		_ = w.cur.Close()
+		w.curCleanup()
+		w.cur, w.curCleanup = nil, nil
+	}
+}
+
+// SplitMinPartSize is the smallest part size accepted for split archives
+const SplitMinPartSize = 1024 * 1024
+
+// ParseSplitPartSize parses the "split" query parameter: "true" selects the configured part size,
+// a size like "100MiB" a custom one, and an empty value or "false" no split at all
+func ParseSplitPartSize(value string) (int64, error) {
+	switch value {
+	case "", "false", "0":
+		return 0, nil
+	case "true", "1":
+		return Setting().SplitPartSize, nil
+	}
+	size, err := humanize.ParseBytes(value)
+	if err != nil || size > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid split size '%s'", value)
+	}
+	if size < SplitMinPartSize {
+		return 0, fmt.Errorf("split size must be at least %s", humanize.IBytes(SplitMinPartSize))
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSplitPartSize(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"":          0,
+		"false":     0,
+		"0":         0,
+		"true":      Setting().SplitPartSize,
+		"1":         Setting().SplitPartSize,
+		"1MiB":      1024 * 1024,
+		"100MB":     100 * 1000 * 1000,
+		"2 GiB":     2 * 1024 * 1024 * 1024,
+		"123456789": 123456789,
+	} {
+		size, err := ParseSplitPartSize(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, size, value)
+	}
+
+	for _, value := range []string{"yes", "-1MiB", "1KiB", "1048575", "100XB", "99999999999999999999EiB"} {
+		_, err := ParseSplitPartSize(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSplitArchiveWriter(t *testing.T) {
+	uploaded := make(map[string]string)
+	newWriter := func(partSize int64) *splitArchiveWriter {
+		return &splitArchiveWriter{
+			partSize: partSize,
+			whole:    sha256.New(),
+			partName: func(i int) string { return fmt.Sprintf("part%d", i) },
+			upload: func(name string, r io.Reader, size int64) error {
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.EqualValues(t, size, len(data))
+				uploaded[name] = string(data)
+				return nil
+			},
+		}
+	}
+	content := strings.Repeat("0123456789", 25)
+
+	w := newWriter(100)
+	for _, chunk := range []string{content[:7], content[7:150], content[150:]} {
+		n, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+		require.Equal(t, len(chunk), n)
+	}
+	require.NoError(t, w.Close())
+	require.Len(t, w.parts, 3)
+	for i, expected := range []string{content[:100], content[100:200], content[200:]} {
+		part := w.parts[i]
+		assert.Equal(t, fmt.Sprintf("part%d", i+1), part.Name)
+		assert.Equal(t, expected, uploaded[part.Name])
+		assert.EqualValues(t, len(expected), part.Size)
+		sum := sha256.Sum256([]byte(expected))
+		assert.Equal(t, hex.EncodeToString(sum[:]), part.SHA256)
+	}
+	assert.EqualValues(t, len(content), w.size)
+	sum := sha256.Sum256([]byte(content))
+	assert.Equal(t, sum[:], w.whole.Sum(nil))
+
+	// a size which is a multiple of the part size leaves no empty part
+	clear(uploaded)
+	w = newWriter(125)
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Len(t, w.parts, 2)
+
+	// without a part size everything goes to a single part
+	w = newWriter(0)
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Len(t, w.parts, 1)
+	assert.Equal(t, content, uploaded["part1"])
+}
@@ -0,0 +1,231 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/gitrepo"
+	"code.gitea.io/gitea/modules/globallock"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/queue"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	"code.gitea.io/gitea/modules/storage"
+	"code.gitea.io/gitea/modules/util"
+	notify_service "code.gitea.io/gitea/services/notify"
+
+	"xorm.io/builder"
+)
+
+// ExportFormats are the archive formats a repository can export
+var ExportFormats = []string{"zip", "tar.gz", "tar"}
+
+// exportTask exports the archives of a pushed tag, failed tasks are retried by RetryExports
+type exportTask struct {
+	RepoID  int64  `json:"repo_id"`
+	Tag     string `json:"tag"`
+	Commit  string `json:"commit"`
+	Attempt int    `json:"attempt"`
+}
+
+var exportQueue *queue.WorkerPoolQueue[*exportTask]
+
+// Failed exports are retried after exportRetryDelay, doubled after each attempt up to exportMaxRetryDelay
+const (
+	exportRetryDelay    = time.Minute
+	exportMaxRetryDelay = time.Hour
+)
+
+// newExportStorage connects to the export bucket, creating it if needed. Every attempt connects again,
+// so that an export failing because the bucket was unreachable succeeds once it is back.
+func newExportStorage(ctx context.Context) (storage.ObjectStorage, error) {
+	return storage.NewMinioStorage(ctx, Setting().ExportStorage)
+}
+
+// exportRetryAfter is the delay before the attempt following the given one
+func exportRetryAfter(attempt int) time.Duration {
+	delay := exportRetryDelay
+	for i := 1; i < attempt && delay < exportMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, exportMaxRetryDelay)
+}
+
+// initExport starts the queue exporting folder archives on tag push, if exports are enabled
+func initExport() error {
+	if !Setting().ExportEnabled {
+		return nil
+	}
+	exportQueue = queue.CreateSimpleQueue(graceful.GetManager().ShutdownContext(), "folder_archive_export", handleExport)
+	if exportQueue == nil {
+		return errors.New("unable to create folder_archive_export queue")
+	}
+	go graceful.GetManager().RunWithCancel(exportQueue)
+	notify_service.RegisterNotifier(&exportNotifier{})
+	return nil
+}
+
+// exportNotifier queues an export for every new tag
+type exportNotifier struct {
+	notify_service.NullNotifier
+}
+
+func (n *exportNotifier) PushCommits(_ context.Context, _ *user_model.User, repo *repo_model.Repository, opts *repo_module.PushUpdateOptions, _ *repo_module.PushCommits) {
+	if !opts.IsNewTag() {
+		return
+	}
+	task := &exportTask{RepoID: repo.ID, Tag: opts.RefFullName.TagName(), Commit: opts.NewCommitID}
+	if err := exportQueue.Push(task); err != nil {
+		log.Error("Unable to queue the folder archive export of %s: %v", repo.FullName(), err)
+	}
+}
+
+// handleExport records the result of the tasks, with the time of the next attempt of the failed ones
+// until they run out of attempts
+func handleExport(tasks ...*exportTask) []*exportTask {
+	ctx := graceful.GetManager().ShutdownContext()
+	for _, task := range tasks {
+		task.Attempt++
+		keys, err := runExport(ctx, task)
+		if errors.Is(err, errExportSkipped) {
+			continue
+		}
+
+		result := &repo_model.ArchiveExportResult{Tag: task.Tag, Commit: task.Commit, Status: PrebuildDone, Attempts: task.Attempt, Keys: keys, Updated: time.Now().UTC()}
+		if err != nil {
+			result.Status, result.Error = PrebuildFailed, err.Error()
+			if task.Attempt < Setting().ExportMaxAttempts {
+				delay := exportRetryAfter(task.Attempt)
+				log.Warn("Folder archive export of repository %d at %s failed, attempt %d, retrying in %v: %v", task.RepoID, task.Tag, task.Attempt, delay, err)
+				result.NextAttempt = util.ToPointer(time.Now().Add(delay).UTC())
+			} else {
+				log.Error("Folder archive export of repository %d at %s failed after %d attempts: %v", task.RepoID, task.Tag, task.Attempt, err)
+			}
+		}
+		if err := UpdateExport(ctx, task.RepoID, func(export *repo_model.ArchiveExport) { export.Last = result }); err != nil {
+			log.Error("UpdateExport: %v", err)
+		}
+	}
+	return nil
+}
+
+// RetryExports queues the failed exports whose next attempt is due, it is run by a cron task so that
+// the retries survive a restart
+func RetryExports(ctx context.Context) error {
+	return db.Iterate(ctx, builder.Eq{"enabled": true}, func(ctx context.Context, export *repo_model.ArchiveExport) error {
+		if export.Last == nil || export.Last.NextAttempt == nil || export.Last.NextAttempt.After(time.Now()) {
+			return nil
+		}
+		return UpdateExport(ctx, export.RepoID, func(export *repo_model.ArchiveExport) {
+			last := export.Last
+			if last == nil || last.NextAttempt == nil {
+				return
+			}
+			task := &exportTask{RepoID: export.RepoID, Tag: last.Tag, Commit: last.Commit, Attempt: last.Attempts}
+			if err := exportQueue.Push(task); err != nil {
+				log.Error("Unable to queue attempt %d of the folder archive export of repository %d at %s: %v", task.Attempt+1, task.RepoID, task.Tag, err)
+				return
+			}
+			last.NextAttempt = nil
+		})
+	})
+}
+
+// errExportSkipped is returned when the repository does not export the tag
+var errExportSkipped = errors.New("folder archive export skipped")
+
+// runExport uploads the configured folder archives of a tag and returns their keys
+func runExport(ctx context.Context, task *exportTask) ([]string, error) {
+	export, err := repo_model.GetArchiveExport(ctx, task.RepoID)
+	if err != nil {
+		return nil, err
+	}
+	if !export.Enabled || len(export.Folders) == 0 || !(len(export.Tags) == 0 || slices.ContainsFunc(export.Tags, func(pattern string) bool {
+		ok, _ := path.Match(pattern, task.Tag)
+		return ok
+	})) {
+		return nil, errExportSkipped
+	}
+
+	dst, err := newExportStorage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := repo_model.GetRepositoryByID(ctx, task.RepoID)
+	if err != nil {
+		return nil, err
+	}
+	gitRepo, err := gitrepo.OpenRepository(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer gitRepo.Close()
+	commit, err := gitRepo.GetCommit(task.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &ArchiveOptions{Symlinks: ArchiveSymlinksKeep, Mtime: ArchiveMtimeCommit}
+	var keys []string
+	for _, folder := range export.Folders {
+		treePath, err := CleanTreePath(folder)
+		if err != nil {
+			return keys, err
+		}
+		name := treePath
+		if name == "" {
+			name = repo.Name
+		}
+		for _, format := range export.Formats {
+			a, err := GetOrCreateCachedArchive(ctx, repo, gitRepo, commit, treePath, fmt.Sprintf("%s-%s", path.Base(name), commit.ID.String()[:7]), format, opts, 0)
+			if err != nil {
+				return keys, err
+			}
+			key := path.Join(repo.OwnerName, repo.Name, task.Tag, name+"."+a.Format)
+			if err := copyCachedArchive(repo.ID, a, dst, key); err != nil {
+				return keys, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// copyCachedArchive uploads a cached archive which was not split to dst
+func copyCachedArchive(repoID int64, a *CachedArchive, dst storage.ObjectStorage, key string) error {
+	obj, err := storage.RepoArchives.Open(ArchiveCachePath(repoID, a.Key, a.Parts[0].Name))
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	_, err = dst.Save(key, struct{ io.Reader }{obj}, a.Size)
+	return err
+}
+
+// UpdateExport changes the export settings or result of a repository
+func UpdateExport(ctx context.Context, repoID int64, update func(export *repo_model.ArchiveExport)) error {
+	releaser, err := globallock.Lock(ctx, "folder_archive_export_"+strconv.FormatInt(repoID, 10))
+	if err != nil {
+		return err
+	}
+	defer releaser()
+
+	export, err := repo_model.GetArchiveExport(ctx, repoID)
+	if err != nil {
+		return err
+	}
+	update(export)
+	return repo_model.SaveArchiveExport(ctx, export)
+}
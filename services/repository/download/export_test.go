@@ -0,0 +1,73 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"testing"
+	"time"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/queue"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Minute, exportRetryAfter(1))
+	assert.Equal(t, 2*time.Minute, exportRetryAfter(2))
+	assert.Equal(t, 4*time.Minute, exportRetryAfter(3))
+	assert.Equal(t, time.Hour, exportRetryAfter(20))
+}
+
+func TestRetryExports(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	queued := make(chan *exportTask, 2)
+	cfg, err := setting.GetQueueSettings(setting.CfgProvider, "folder_archive_export")
+	require.NoError(t, err)
+	exportQueue, err = queue.NewWorkerPoolQueueWithContext(t.Context(), "folder_archive_export", cfg, func(tasks ...*exportTask) []*exportTask {
+		for _, task := range tasks {
+			queued <- task
+		}
+		return nil
+	}, true)
+	require.NoError(t, err)
+	go exportQueue.Run()
+	defer func() {
+		exportQueue.ShutdownWait(time.Second)
+		exportQueue = nil
+	}()
+
+	due := &repo_model.ArchiveExportResult{Tag: "v1", Commit: "c1", Status: PrebuildFailed, Attempts: 2, NextAttempt: util.ToPointer(time.Now().Add(-time.Minute))}
+	require.NoError(t, repo_model.SaveArchiveExport(t.Context(), &repo_model.ArchiveExport{RepoID: 1, Enabled: true, Last: due}))
+	later := &repo_model.ArchiveExportResult{Tag: "v2", Commit: "c2", Status: PrebuildFailed, Attempts: 1, NextAttempt: util.ToPointer(time.Now().Add(time.Hour))}
+	require.NoError(t, repo_model.SaveArchiveExport(t.Context(), &repo_model.ArchiveExport{RepoID: 2, Enabled: true, Last: later}))
+
+	require.NoError(t, RetryExports(t.Context()))
+	select {
+	case task := <-queued:
+		assert.Equal(t, &exportTask{RepoID: 1, Tag: "v1", Commit: "c1", Attempt: 2}, task)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "Timeout: the due export was not queued")
+	}
+
+	export, err := repo_model.GetArchiveExport(t.Context(), 1)
+	require.NoError(t, err)
+	assert.Nil(t, export.Last.NextAttempt)
+	export, err = repo_model.GetArchiveExport(t.Context(), 2)
+	require.NoError(t, err)
+	assert.NotNil(t, export.Last.NextAttempt)
+
+	// the retry is queued only once
+	require.NoError(t, RetryExports(t.Context()))
+	select {
+	case task := <-queued:
+		assert.FailNow(t, "The export was queued again", "%v", task)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+)
+
+// Init starts the queues building folder archives and firing the download webhooks in the background
+func Init(ctx context.Context) error {
+	if err := initPrebuild(); err != nil {
+		return err
+	}
+	if err := initSplit(); err != nil {
+		return err
+	}
+	if err := initExport(); err != nil {
+		return err
+	}
+	return initWebhook()
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	git_model "code.gitea.io/gitea/models/git"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/globallock"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/storage"
+
+	"xorm.io/builder"
+)
+
+const (
+	// lfsStorageReportMaxProblems limits the objects listed in the report, all of them are counted
+	lfsStorageReportMaxProblems = 1000
+
+	lfsProblemMissing    = "missing"
+	lfsProblemSize       = "size"
+	lfsProblemChecksum   = "checksum"
+	lfsProblemUnreadable = "unreadable"
+)
+
+// LFSStorageProblem is an LFS meta object whose content is missing or does not match its pointer
+type LFSStorageProblem struct {
+	RepoID   int64  `json:"repo_id"`
+	RepoName string `json:"repo_name,omitempty"`
+	Oid      string `json:"oid"`
+	Size     int64  `json:"size"`
+	// Problem is missing, size, checksum or unreadable
+	Problem    string `json:"problem"`
+	ActualSize int64  `json:"actual_size,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// LFSStorageReport is the result of the last LFS storage scan, Finished is zero while it is running
+type LFSStorageReport struct {
+	Started         time.Time            `json:"started"`
+	Finished        time.Time            `json:"finished"`
+	VerifyChecksums bool                 `json:"verify_checksums"`
+	MetaObjects     int64                `json:"meta_objects"`
+	Objects         int64                `json:"objects"`
+	Bytes           int64                `json:"bytes"`
+	Missing         int64                `json:"missing"`
+	Corrupt         int64                `json:"corrupt"`
+	Problems        []*LFSStorageProblem `json:"problems"`
+	Truncated       bool                 `json:"truncated,omitempty"`
+	Error           string               `json:"error,omitempty"`
+}
+
+// lfsStorageReportPath is where the last LFS storage scan is kept in the repository archive storage,
+// which is shared by all instances next to the other folder download state
+var lfsStorageReportPath = path.Join(cacheDir, "lfs-storage-report.json")
+
+// ReadLFSStorageReport returns the result of the last LFS storage scan
+func ReadLFSStorageReport() (*LFSStorageReport, error) {
+	obj, err := storage.RepoArchives.Open(lfsStorageReportPath)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	report := &LFSStorageReport{}
+	return report, json.NewDecoder(obj).Decode(report)
+}
+
+func saveLFSStorageReport(report *LFSStorageReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = storage.RepoArchives.Save(lfsStorageReportPath, strings.NewReader(string(data)), int64(len(data)))
+	return err
+}
+
+// DeleteRepositoryLFSProblems removes the objects of a deleted repository from the report of the last
+// LFS storage scan, the report of a running scan is left as it is
+func DeleteRepositoryLFSProblems(ctx context.Context, repoID int64) {
+	releaser, err := globallock.Lock(ctx, "lfs_storage_report")
+	if err != nil {
+		log.Error("DeleteRepositoryLFSProblems: %v", err)
+		return
+	}
+	defer releaser()
+
+	report, err := ReadLFSStorageReport()
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Error("ReadLFSStorageReport: %v", err)
+		}
+		return
+	}
+	if report.Finished.IsZero() {
+		return
+	}
+	problems := report.Problems[:0]
+	for _, p := range report.Problems {
+		if p.RepoID != repoID {
+			problems = append(problems, p)
+			continue
+		}
+		if p.Problem == lfsProblemMissing {
+			report.Missing--
+		} else {
+			report.Corrupt--
+		}
+	}
+	if len(problems) == len(report.Problems) {
+		return
+	}
+	report.Problems = problems
+	if err := saveLFSStorageReport(report); err != nil {
+		log.Error("saveLFSStorageReport: %v", err)
+	}
+}
+
+// ScanLFSStorage checks the object of every LFS meta object in the LFS storage, an object shared by several
+// repositories is checked once but reported for each of them
+func ScanLFSStorage(ctx context.Context, verifyChecksums bool) error {
+	report := &LFSStorageReport{Started: time.Now(), VerifyChecksums: verifyChecksums, Problems: []*LFSStorageProblem{}}
+	if err := saveLFSStorageReport(report); err != nil {
+		return err
+	}
+
+	checked := make(map[string]*LFSStorageProblem)
+	repoNames := make(map[int64]string)
+	err := db.Iterate(ctx, builder.Gt{"id": 0}, func(ctx context.Context, meta *git_model.LFSMetaObject) error {
+		report.MetaObjects++
+		problem, ok := checked[meta.Oid]
+		if !ok {
+			report.Objects++
+			report.Bytes += meta.Size
+			problem = checkLFSObject(meta.Pointer, verifyChecksums)
+			checked[meta.Oid] = problem
+		}
+		if problem == nil {
+			return nil
+		}
+		if problem.Problem == lfsProblemMissing {
+			report.Missing++
+		} else {
+			report.Corrupt++
+		}
+		if len(report.Problems) >= lfsStorageReportMaxProblems {
+			report.Truncated = true
+			return nil
+		}
+		name, ok := repoNames[meta.RepositoryID]
+		if !ok {
+			// the name stays empty for meta objects left behind by a deleted repository
+			if repo, err := repo_model.GetRepositoryByID(ctx, meta.RepositoryID); err == nil {
+				name = repo.FullName()
+			}
+			repoNames[meta.RepositoryID] = name
+		}
+		p := *problem
+		p.RepoID, p.RepoName = meta.RepositoryID, name
+		report.Problems = append(report.Problems, &p)
+		return nil
+	})
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Finished = time.Now()
+	if saveErr := saveLFSStorageReport(report); saveErr != nil {
+		log.Error("saveLFSStorageReport: %v", saveErr)
+	}
+	log.Info("LFS storage scan: %d objects checked, %d missing, %d corrupt", report.Objects, report.Missing, report.Corrupt)
+	return err
+}
+
+// checkLFSObject returns the problem of an LFS object in the storage, nil if there is none
+func checkLFSObject(pointer lfs.Pointer, verifyChecksum bool) *LFSStorageProblem {
+	problem := &LFSStorageProblem{Oid: pointer.Oid, Size: pointer.Size}
+	info, err := storage.LFS.Stat(pointer.RelativePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			problem.Problem = lfsProblemMissing
+		} else {
+			problem.Problem, problem.Error = lfsProblemUnreadable, err.Error()
+		}
+		return problem
+	}
+	if info.Size() != pointer.Size {
+		problem.Problem, problem.ActualSize = lfsProblemSize, info.Size()
+		return problem
+	}
+	if !verifyChecksum {
+		return nil
+	}
+
+	rc, err := lfs.ReadMetaObject(pointer)
+	if err != nil {
+		problem.Problem, problem.Error = lfsProblemUnreadable, err.Error()
+		return problem
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, rc); err != nil {
+		problem.Problem, problem.Error = lfsProblemUnreadable, err.Error()
+		return problem
+	}
+	if hex.EncodeToString(h.Sum(nil)) != pointer.Oid {
+		problem.Problem = lfsProblemChecksum
+		return problem
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models/unittest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteRepositoryLFSProblems(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	require.NoError(t, saveLFSStorageReport(&LFSStorageReport{
+		Finished: time.Now(),
+		Missing:  2,
+		Corrupt:  1,
+		Problems: []*LFSStorageProblem{
+			{RepoID: 1, Oid: "a", Problem: lfsProblemMissing},
+			{RepoID: 2, Oid: "b", Problem: lfsProblemMissing},
+			{RepoID: 1, Oid: "c", Problem: lfsProblemChecksum},
+		},
+	}))
+
+	DeleteRepositoryLFSProblems(t.Context(), 1)
+	report, err := ReadLFSStorageReport()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, report.Missing)
+	assert.EqualValues(t, 0, report.Corrupt)
+	require.Len(t, report.Problems, 1)
+	assert.EqualValues(t, 2, report.Problems[0].RepoID)
+
+	// the report of a running scan is not touched
+	require.NoError(t, saveLFSStorageReport(&LFSStorageReport{
+		Problems: []*LFSStorageProblem{{RepoID: 2, Oid: "b", Problem: lfsProblemMissing}},
+	}))
+	DeleteRepositoryLFSProblems(t.Context(), 2)
+	report, err = ReadLFSStorageReport()
+	require.NoError(t, err)
+	assert.Len(t, report.Problems, 1)
+}
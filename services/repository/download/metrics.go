@@ -0,0 +1,61 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "gitea"
+
+var (
+	archiveGenerationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "download",
+		Name:      "archive_generation_duration_seconds",
+		Help:      "Time spent generating folder archives",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"format"})
+
+	archiveJobsInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "download",
+		Name:      "archive_jobs_in_progress",
+		Help:      "Number of folder archives being generated",
+	})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "download",
+		Name:      "cache_lookups_total",
+		Help:      "Download cache lookups by cache and result (hit or miss)",
+	}, []string{"cache", "result"})
+)
+
+// MetricsCollectors returns the metrics of archive generation and of the download caches,
+// the caller registers them with the other download metrics
+func MetricsCollectors() []prometheus.Collector {
+	return []prometheus.Collector{archiveGenerationDuration, archiveJobsInProgress, cacheLookups}
+}
+
+// ObserveArchiveGeneration tracks a running archive job, the returned function must be called when it is done
+func ObserveArchiveGeneration(format string) func() {
+	start := time.Now()
+	archiveJobsInProgress.Inc()
+	return func() {
+		archiveJobsInProgress.Dec()
+		archiveGenerationDuration.WithLabelValues(format).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveCacheLookup counts a hit or a miss of one of the download caches
+func ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
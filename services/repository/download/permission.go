@@ -0,0 +1,63 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+
+	org_model "code.gitea.io/gitea/models/organization"
+	access_model "code.gitea.io/gitea/models/perm/access"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// IsBrowseOnlyUser reports whether doer may read the code of repo in the web UI but must not export it,
+// because they only reach it through browse-only teams. The caller must have checked that doer is not an
+// admin of the repository.
+func IsBrowseOnlyUser(ctx context.Context, doer *user_model.User, repo *repo_model.Repository) bool {
+	if doer == nil || doer.IsAdmin {
+		return false
+	}
+	if err := repo.LoadOwner(ctx); err != nil {
+		// fail closed, browsing still works
+		log.Error("LoadOwner: %v", err)
+		return true
+	}
+	if !repo.Owner.IsOrganization() {
+		return false
+	}
+	browseOnly, err := org_model.IsUserBrowseOnlyInRepo(ctx, repo.OwnerID, doer.ID, repo.ID)
+	if err != nil {
+		log.Error("IsUserBrowseOnlyInRepo: %v", err)
+		return true
+	}
+	return browseOnly
+}
+
+// CheckUploadArchiveOverSSH decides whether doer may run git-upload-archive on repo over SSH, a nil doer
+// is a deploy key. The archive is then generated by the SSH command, out of reach of the archive worker pool,
+// the rate limits, the bandwidth caps and the daily quotas of the web server, so it is refused while any of
+// them is configured. The anonymous archive policy and the bot detection do not apply, SSH users are
+// authenticated and send no user agent. A refusal is a util.ErrPermissionDenied.
+func CheckUploadArchiveOverSSH(ctx context.Context, repo *repo_model.Repository, doer *user_model.User) error {
+	s := Setting()
+	if s.MaxConcurrentArchives > 0 || s.UserRateLimit > 0 || s.IPRateLimit > 0 ||
+		s.ArchiveBandwidthPerConnection > 0 || s.ArchiveBandwidthPerUser > 0 ||
+		s.DailyQuotaPerUser >= 0 || s.DailyQuotaPerOrg >= 0 {
+		return util.NewPermissionDeniedErrorf("archive downloads are limited on this server, use \"git archive --remote\" over HTTP(S) instead")
+	}
+	if doer == nil {
+		return nil
+	}
+	perm, err := access_model.GetUserRepoPermission(ctx, repo, doer)
+	if err != nil {
+		return err
+	}
+	if !perm.IsAdmin() && IsBrowseOnlyUser(ctx, doer, repo) {
+		return util.NewPermissionDeniedErrorf("browse-only access does not allow archive downloads")
+	}
+	return nil
+}
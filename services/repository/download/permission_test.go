@@ -0,0 +1,41 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/models/db"
+	org_model "code.gitea.io/gitea/models/organization"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUploadArchiveOverSSH(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 3})
+	member := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 4})
+	owner := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	assert.NoError(t, CheckUploadArchiveOverSSH(t.Context(), repo, member))
+	// deploy keys have no user
+	assert.NoError(t, CheckUploadArchiveOverSSH(t.Context(), repo, nil))
+
+	_, err := db.GetEngine(t.Context()).ID(2).Cols("browse_only").Update(&org_model.Team{BrowseOnly: true})
+	require.NoError(t, err)
+	assert.ErrorIs(t, CheckUploadArchiveOverSSH(t.Context(), repo, member), util.ErrPermissionDenied)
+	assert.NoError(t, CheckUploadArchiveOverSSH(t.Context(), repo, owner))
+
+	limited := *Setting()
+	limited.MaxConcurrentArchives = 2
+	defer test.MockVariableValue(&Setting, func() *Settings { return &limited })()
+	assert.ErrorIs(t, CheckUploadArchiveOverSSH(t.Context(), repo, owner), util.ErrPermissionDenied)
+	assert.ErrorIs(t, CheckUploadArchiveOverSSH(t.Context(), repo, nil), util.ErrPermissionDenied)
+}
@@ -0,0 +1,327 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/gitrepo"
+	"code.gitea.io/gitea/modules/globallock"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/queue"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	"code.gitea.io/gitea/modules/storage"
+	notify_service "code.gitea.io/gitea/services/notify"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// prebuildConfig lists the folder archives to build when a matching ref is pushed
+	prebuildConfig = ".gitea/archives"
+	// prebuildConfigMaxSize is the largest config file read
+	prebuildConfigMaxSize = 64 * 1024
+	// prebuildStatusName keeps the status of the pre-builds of a repository in the archive storage
+	prebuildStatusName = "prebuilds.json"
+)
+
+// Statuses of a folder archive pre-build
+const (
+	PrebuildRunning = "running"
+	PrebuildDone    = "done"
+	PrebuildFailed  = "failed"
+)
+
+// prebuildEntry is one folder of the ".gitea/archives.yaml" config:
+//
+//	archives:
+//	  - path: sdk
+//	    formats: [zip, tar.gz]
+//	    branches: [main, "release/*"]
+//	    tags: ["v*"]
+//	    mtime: file
+//
+// Without branches and tags only the default branch is built.
+type prebuildEntry struct {
+	Path     string   `yaml:"path"`
+	Formats  []string `yaml:"formats"`
+	Branches []string `yaml:"branches"`
+	Tags     []string `yaml:"tags"`
+	Symlinks string   `yaml:"symlinks"`
+	EOL      string   `yaml:"eol"`
+	Mtime    string   `yaml:"mtime"`
+}
+
+type prebuildConfigFile struct {
+	Archives []*prebuildEntry `yaml:"archives"`
+}
+
+// matches reports whether a push to ref builds the entry
+func (e *prebuildEntry) matches(ref git.RefName, defaultBranch string) bool {
+	patterns, name := e.Branches, ref.BranchName()
+	if ref.IsTag() {
+		patterns, name = e.Tags, ref.TagName()
+	} else if len(e.Branches) == 0 && len(e.Tags) == 0 {
+		return ref.IsBranch() && name == defaultBranch
+	}
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+}
+
+// Prebuild is the status of the latest pre-build of a folder archive
+type Prebuild struct {
+	Ref     string    `json:"ref"`
+	Commit  string    `json:"commit"`
+	Path    string    `json:"path"`
+	Format  string    `json:"format"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Size    int64     `json:"size"`
+	Updated time.Time `json:"updated"`
+}
+
+// prebuildTask asks to build the archives configured for a pushed ref
+type prebuildTask struct {
+	RepoID int64  `json:"repo_id"`
+	Ref    string `json:"ref"`
+	Commit string `json:"commit"`
+}
+
+var prebuildQueue *queue.WorkerPoolQueue[*prebuildTask]
+
+// initPrebuild starts the queue building folder archives on push, if pre-builds are enabled
+func initPrebuild() error {
+	if !Setting().PrebuildEnabled {
+		return nil
+	}
+	prebuildQueue = queue.CreateUniqueQueue(graceful.GetManager().ShutdownContext(), "folder_archive_prebuild", handlePrebuild)
+	if prebuildQueue == nil {
+		return errors.New("unable to create folder_archive_prebuild queue")
+	}
+	go graceful.GetManager().RunWithCancel(prebuildQueue)
+	notify_service.RegisterNotifier(&prebuildNotifier{})
+	return nil
+}
+
+// QueuePrebuild queues a build of the archives the config at commitID selects for ref
+func QueuePrebuild(repoID int64, ref git.RefName, commitID string) error {
+	if prebuildQueue == nil {
+		return errors.New("the folder_archive_prebuild queue is not running")
+	}
+	return prebuildQueue.Push(&prebuildTask{RepoID: repoID, Ref: ref.String(), Commit: commitID})
+}
+
+// prebuildNotifier queues a pre-build for every pushed branch or tag
+type prebuildNotifier struct {
+	notify_service.NullNotifier
+}
+
+func (n *prebuildNotifier) PushCommits(_ context.Context, _ *user_model.User, repo *repo_model.Repository, opts *repo_module.PushUpdateOptions, _ *repo_module.PushCommits) {
+	if opts.IsDelRef() || repo.IsEmpty {
+		return
+	}
+	if err := prebuildQueue.Push(&prebuildTask{RepoID: repo.ID, Ref: opts.RefFullName.String(), Commit: opts.NewCommitID}); err != nil {
+		log.Error("Unable to queue the folder archive pre-build of %s: %v", repo.FullName(), err)
+	}
+}
+
+func handlePrebuild(tasks ...*prebuildTask) []*prebuildTask {
+	ctx := graceful.GetManager().ShutdownContext()
+	for _, task := range tasks {
+		if err := runPrebuild(ctx, task); err != nil {
+			log.Error("Folder archive pre-build of repository %d at %s failed: %v", task.RepoID, task.Ref, err)
+		}
+	}
+	return nil
+}
+
+// runPrebuild builds the archives the config at the pushed commit selects for the pushed ref
+func runPrebuild(ctx context.Context, task *prebuildTask) error {
+	repo, err := repo_model.GetRepositoryByID(ctx, task.RepoID)
+	if err != nil {
+		return err
+	}
+	gitRepo, err := gitrepo.OpenRepository(ctx, repo)
+	if err != nil {
+		return err
+	}
+	defer gitRepo.Close()
+	commit, err := gitRepo.GetCommit(task.Commit)
+	if err != nil {
+		return err
+	}
+	ref := git.RefName(task.Ref)
+	config, configName, err := readPrebuildConfig(commit)
+	// a broken config is listed in the settings as a failed pre-build of the config file itself
+	configStatus := &Prebuild{Ref: ref.ShortName(), Commit: task.Commit, Path: configName}
+	if err != nil {
+		setPrebuildStatus(ctx, repo.ID, configStatus, PrebuildFailed, err)
+		return err
+	} else if config == nil {
+		return nil
+	}
+	deletePrebuildStatus(ctx, repo.ID, configStatus)
+
+	built := 0
+	for _, entry := range config.Archives {
+		if !entry.matches(ref, repo.DefaultBranch) {
+			continue
+		}
+		treePath, err := CleanTreePath(entry.Path)
+		if err != nil {
+			err = fmt.Errorf("invalid path '%s' in %s: %w", entry.Path, configName, err)
+			setPrebuildStatus(ctx, repo.ID, configStatus, PrebuildFailed, err)
+			return err
+		}
+		opts := &ArchiveOptions{Symlinks: entry.Symlinks, EOL: entry.EOL, Mtime: entry.Mtime}
+		if opts.Symlinks == "" {
+			opts.Symlinks = ArchiveSymlinksKeep
+		}
+		if opts.Mtime == "" {
+			opts.Mtime = ArchiveMtimeCommit
+		}
+		formats := entry.Formats
+		if len(formats) == 0 {
+			formats = []string{"zip"}
+		}
+		if !slices.Contains([]string{ArchiveSymlinksKeep, ArchiveSymlinksFollow, ArchiveSymlinksSkip}, opts.Symlinks) ||
+			!slices.Contains([]string{"", ArchiveEOLLF, ArchiveEOLCRLF}, opts.EOL) ||
+			!slices.Contains([]string{ArchiveMtimeCommit, ArchiveMtimeFile}, opts.Mtime) ||
+			slices.ContainsFunc(formats, func(format string) bool { return !slices.Contains([]string{"zip", "tar", "tar.gz", "tgz"}, format) }) {
+			err := fmt.Errorf("invalid symlinks, eol, mtime or formats of '%s' in %s", entry.Path, configName)
+			setPrebuildStatus(ctx, repo.ID, configStatus, PrebuildFailed, err)
+			return err
+		}
+		for _, format := range formats {
+			if built >= Setting().PrebuildMaxArchives {
+				log.Warn("Folder archive pre-build of %s at %s stopped after %d archives", repo.FullName(), task.Ref, built)
+				return nil
+			}
+			built++
+			status := &Prebuild{Ref: ref.ShortName(), Commit: task.Commit, Path: treePath, Format: ArchiveExt(format)}
+			setPrebuildStatus(ctx, repo.ID, status, PrebuildRunning, nil)
+
+			folderName := path.Base(treePath)
+			if treePath == "" {
+				folderName = repo.Name
+			}
+			a, err := GetOrCreateCachedArchive(ctx, repo, gitRepo, commit, treePath, fmt.Sprintf("%s-%s", folderName, commit.ID.String()[:7]), format, opts, 0)
+			if err == nil {
+				status.Size = a.Size
+			}
+			setPrebuildStatus(ctx, repo.ID, status, PrebuildDone, err)
+		}
+	}
+	return nil
+}
+
+// readPrebuildConfig reads ".gitea/archives.yaml" (or ".yml") of commit and returns it with
+// the name of the file, nil if there is none
+func readPrebuildConfig(commit *git.Commit) (*prebuildConfigFile, string, error) {
+	for _, ext := range []string{".yaml", ".yml"} {
+		name := prebuildConfig + ext
+		content, err := commit.GetFileContent(name, prebuildConfigMaxSize)
+		if git.IsErrNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, name, err
+		}
+		config := &prebuildConfigFile{}
+		if err := yaml.Unmarshal([]byte(content), config); err != nil {
+			return nil, name, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return config, name, nil
+	}
+	return nil, "", nil
+}
+
+func prebuildStatusPath(repoID int64) string {
+	return path.Join(cacheDir, strconv.FormatInt(repoID, 10), prebuildStatusName)
+}
+
+// ReadPrebuilds returns the status of the latest pre-build of each folder archive of a repository
+func ReadPrebuilds(repoID int64) ([]*Prebuild, error) {
+	obj, err := storage.RepoArchives.Open(prebuildStatusPath(repoID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	var prebuilds []*Prebuild
+	if err := json.NewDecoder(obj).Decode(&prebuilds); err != nil {
+		return nil, err
+	}
+	return prebuilds, nil
+}
+
+// setPrebuildStatus records the status of a pre-build, replacing the one of the same ref, path and format.
+// An error turns the status into PrebuildFailed.
+func setPrebuildStatus(ctx context.Context, repoID int64, prebuild *Prebuild, status string, buildErr error) {
+	prebuild.Status, prebuild.Error, prebuild.Updated = status, "", time.Now().UTC()
+	if buildErr != nil {
+		prebuild.Status, prebuild.Error = PrebuildFailed, buildErr.Error()
+	}
+	updatePrebuilds(ctx, repoID, func(prebuilds []*Prebuild) []*Prebuild {
+		prebuilds = slices.DeleteFunc(prebuilds, prebuild.sameArchive)
+		prebuilds = append(prebuilds, prebuild)
+		slices.SortFunc(prebuilds, func(a, b *Prebuild) int {
+			return strings.Compare(a.Ref+"\x00"+a.Path+"\x00"+a.Format, b.Ref+"\x00"+b.Path+"\x00"+b.Format)
+		})
+		return prebuilds
+	})
+}
+
+// deletePrebuildStatus removes the status of the same ref, path and format as prebuild, if there is one
+func deletePrebuildStatus(ctx context.Context, repoID int64, prebuild *Prebuild) {
+	prebuilds, err := ReadPrebuilds(repoID)
+	if err != nil || !slices.ContainsFunc(prebuilds, prebuild.sameArchive) {
+		return
+	}
+	updatePrebuilds(ctx, repoID, func(prebuilds []*Prebuild) []*Prebuild {
+		return slices.DeleteFunc(prebuilds, prebuild.sameArchive)
+	})
+}
+
+func (p *Prebuild) sameArchive(other *Prebuild) bool {
+	return p.Ref == other.Ref && p.Path == other.Path && p.Format == other.Format
+}
+
+// updatePrebuilds rewrites the pre-build statuses of a repository with update
+func updatePrebuilds(ctx context.Context, repoID int64, update func([]*Prebuild) []*Prebuild) {
+	releaser, err := globallock.Lock(ctx, "folder_archive_prebuilds_"+strconv.FormatInt(repoID, 10))
+	if err != nil {
+		log.Error("updatePrebuilds: %v", err)
+		return
+	}
+	defer releaser()
+
+	prebuilds, err := ReadPrebuilds(repoID)
+	if err != nil {
+		log.Error("ReadPrebuilds: %v", err)
+	}
+	data, err := json.Marshal(update(prebuilds))
+	if err != nil {
+		log.Error("updatePrebuilds: %v", err)
+		return
+	}
+	if _, err := storage.RepoArchives.Save(prebuildStatusPath(repoID), strings.NewReader(string(data)), int64(len(data))); err != nil {
+		log.Error("updatePrebuilds: %v", err)
+	}
+}
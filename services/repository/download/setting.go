@@ -0,0 +1,258 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Policies for anonymous archive requests
+const (
+	AnonymousArchivePolicyAllow   = "allow"
+	AnonymousArchivePolicySignIn  = "signin"
+	AnonymousArchivePolicyCaptcha = "captcha"
+)
+
+// What a download of an LFS pointer gets when its object is not on the server
+const (
+	LFSMissingObjectError   = "error"
+	LFSMissingObjectPointer = "pointer"
+)
+
+// Settings holds the options of the [repository.folder_download] section
+type Settings struct {
+	// ConfirmSizeThreshold is the folder size above which the UI asks for confirmation, -1 disables it
+	ConfirmSizeThreshold int64
+	// FileMtimeMaxCommits limits the history read to find the last commit of each archived file
+	FileMtimeMaxCommits int
+	// ArchiveMaxFiles and ArchiveMaxSize (uncompressed bytes) limit the entries of an archive built in-process,
+	// where followed symlinks can repeat a tree many times, -1 means no limit
+	ArchiveMaxFiles int
+	ArchiveMaxSize  int64
+
+	// MaxConcurrentArchives caps the number of archives generated at the same time, 0 (the default) means no limit
+	MaxConcurrentArchives int
+	// ArchiveQueueTimeout is how long a request waits for a free archive worker before getting a 429
+	ArchiveQueueTimeout time.Duration
+
+	// The rate limits are archives per minute with a token bucket of the given burst, 0 (the default) disables a limit.
+	// Signed-in users are limited per user and per IP, anonymous requests only per IP with their own limit.
+	UserRateLimit      int
+	UserRateBurst      int
+	IPRateLimit        int
+	IPRateBurst        int
+	AnonymousRateLimit int
+	AnonymousRateBurst int
+
+	// Bandwidth caps in bytes per second, per connection and shared by all connections of a user, -1 means no limit
+	ArchiveBandwidthPerConnection int64
+	ArchiveBandwidthPerUser       int64
+	RawBandwidthPerConnection     int64
+	RawBandwidthPerUser           int64
+	// DailyQuotaPerUser and DailyQuotaPerOrg limit the bytes served per UTC day to a user (or anonymous IP)
+	// and from the repositories of an organization, -1 means no limit
+	DailyQuotaPerUser int64
+	DailyQuotaPerOrg  int64
+
+	// AnonymousArchivePolicy is allow, signin or captcha for archive requests without a signed-in user
+	AnonymousArchivePolicy string
+	// BlockBots refuses archive generation to user agents containing one of BotUserAgents (lower case)
+	BlockBots     bool
+	BotUserAgents []string
+
+	// AuditLogEnabled records raw, media and archive downloads of private repositories in the database,
+	// AuditPublicRepositories extends it to all repositories
+	AuditLogEnabled         bool
+	AuditPublicRepositories bool
+	// AuditLogRetention is how long the records are kept, 0 keeps them forever
+	AuditLogRetention time.Duration
+
+	// WebhookEnabled fires the repository_download event to Gitea and Gogs webhooks which subscribe to it
+	WebhookEnabled bool
+
+	// ZipAESEnabled allows AES-256 encrypted zip archives ("zip-aes") of folders, each repository
+	// turns them on in its archive policy
+	ZipAESEnabled bool
+
+	// SplitPartSize is the default part size of split archives ("?split=true")
+	SplitPartSize int64
+	// ArchiveCacheTTL is how long cached folder archives are kept in the repository archive storage, 0 keeps them forever
+	ArchiveCacheTTL time.Duration
+
+	// ArchiveSignaturesEnabled offers detached signatures of folder archives made with the instance signing key
+	ArchiveSignaturesEnabled bool
+
+	// PrebuildEnabled builds the folder archives listed in ".gitea/archives.yaml" when a matching ref is pushed,
+	// PrebuildMaxArchives limits the archives built per push
+	PrebuildEnabled     bool
+	PrebuildMaxArchives int
+
+	// ExportEnabled lets repositories export folder archives to the S3-compatible ExportStorage when a tag is pushed,
+	// a failed export is retried by the queue up to ExportMaxAttempts times
+	ExportEnabled     bool
+	ExportStorage     *setting.Storage
+	ExportMaxAttempts int
+
+	// DigestMaxSize is the largest git blob hashed before it is served to send its SHA-256 digest, -1 means no limit.
+	// The blob is read twice on a cache miss, so the default is small. Range requests are only sent a digest
+	// which is already cached.
+	// LFS objects and cached archives always get one, their SHA-256 is already known.
+	DigestMaxSize int64
+
+	// VerifyLFSDownloads checks the SHA-256 of LFS objects served through Gitea and aborts corrupt downloads
+	VerifyLFSDownloads bool
+
+	// LFSMissingObject is what a download of an LFS pointer without its object gets: "error" answers with
+	// LFSMissingStatus (404 or 424) and a page for browsers, "pointer" serves the pointer file itself
+	LFSMissingObject string
+	LFSMissingStatus int
+}
+
+// defaultArchiveBotUserAgents are substrings of the user agents of well-known crawlers. Generic markers like
+// "bot" or HTTP libraries would also match CI systems and scripts of real users, admins can add them.
+var defaultArchiveBotUserAgents = []string{
+	"googlebot", "bingbot", "yandexbot", "duckduckbot", "applebot", "ahrefsbot", "semrushbot", "mj12bot", "petalbot",
+	"dotbot", "gptbot", "ccbot", "crawler", "spider", "slurp", "facebookexternalhit", "bingpreview", "headlesschrome", "scrapy",
+}
+
+// Setting returns the folder download options, they are loaded on first use
+var Setting = sync.OnceValue(func() *Settings {
+	s := &Settings{
+		ConfirmSizeThreshold: 100 * 1024 * 1024,
+		FileMtimeMaxCommits:  10000,
+		ArchiveMaxFiles:      100000,
+		ArchiveMaxSize:       10 * 1024 * 1024 * 1024,
+
+		ArchiveQueueTimeout: 10 * time.Second,
+
+		UserRateBurst:      10,
+		IPRateBurst:        15,
+		AnonymousRateBurst: 3,
+
+		ArchiveBandwidthPerConnection: -1,
+		ArchiveBandwidthPerUser:       -1,
+		RawBandwidthPerConnection:     -1,
+		RawBandwidthPerUser:           -1,
+		DailyQuotaPerUser:             -1,
+		DailyQuotaPerOrg:              -1,
+
+		AnonymousArchivePolicy: AnonymousArchivePolicyAllow,
+		BotUserAgents:          defaultArchiveBotUserAgents,
+
+		AuditLogRetention: 90 * 24 * time.Hour,
+
+		SplitPartSize:   2 * 1000 * 1000 * 1000,
+		ArchiveCacheTTL: 7 * 24 * time.Hour,
+
+		PrebuildMaxArchives: 20,
+
+		ExportStorage:     &setting.Storage{Type: setting.MinioStorageType},
+		ExportMaxAttempts: 5,
+
+		DigestMaxSize:      1024 * 1024,
+		VerifyLFSDownloads: true,
+
+		LFSMissingObject: LFSMissingObjectError,
+		LFSMissingStatus: http.StatusNotFound,
+	}
+	if setting.CfgProvider == nil {
+		return s
+	}
+	sec := setting.CfgProvider.Section("repository.folder_download")
+	s.ConfirmSizeThreshold = mustBytes(sec, "CONFIRM_SIZE_THRESHOLD", "100MiB")
+	s.FileMtimeMaxCommits = sec.Key("FILE_MTIME_MAX_COMMITS").MustInt(s.FileMtimeMaxCommits)
+	s.ArchiveMaxFiles = sec.Key("ARCHIVE_MAX_FILES").MustInt(s.ArchiveMaxFiles)
+	s.ArchiveMaxSize = mustBytes(sec, "ARCHIVE_MAX_SIZE", "10GiB")
+	s.MaxConcurrentArchives = sec.Key("MAX_CONCURRENT_ARCHIVES").MustInt(s.MaxConcurrentArchives)
+	s.ArchiveQueueTimeout = sec.Key("ARCHIVE_QUEUE_TIMEOUT").MustDuration(s.ArchiveQueueTimeout)
+	s.UserRateLimit = sec.Key("USER_RATE_LIMIT").MustInt(s.UserRateLimit)
+	s.UserRateBurst = sec.Key("USER_RATE_BURST").MustInt(s.UserRateBurst)
+	s.IPRateLimit = sec.Key("IP_RATE_LIMIT").MustInt(s.IPRateLimit)
+	s.IPRateBurst = sec.Key("IP_RATE_BURST").MustInt(s.IPRateBurst)
+	s.AnonymousRateLimit = sec.Key("ANONYMOUS_RATE_LIMIT").MustInt(s.AnonymousRateLimit)
+	s.AnonymousRateBurst = sec.Key("ANONYMOUS_RATE_BURST").MustInt(s.AnonymousRateBurst)
+	s.ArchiveBandwidthPerConnection = mustBytes(sec, "ARCHIVE_BANDWIDTH_PER_CONNECTION", "-1")
+	s.ArchiveBandwidthPerUser = mustBytes(sec, "ARCHIVE_BANDWIDTH_PER_USER", "-1")
+	s.RawBandwidthPerConnection = mustBytes(sec, "RAW_BANDWIDTH_PER_CONNECTION", "-1")
+	s.RawBandwidthPerUser = mustBytes(sec, "RAW_BANDWIDTH_PER_USER", "-1")
+	s.DailyQuotaPerUser = mustBytes(sec, "DAILY_QUOTA_PER_USER", "-1")
+	s.DailyQuotaPerOrg = mustBytes(sec, "DAILY_QUOTA_PER_ORG", "-1")
+	s.AnonymousArchivePolicy = sec.Key("ANONYMOUS_ARCHIVE_POLICY").In(s.AnonymousArchivePolicy,
+		[]string{AnonymousArchivePolicyAllow, AnonymousArchivePolicySignIn, AnonymousArchivePolicyCaptcha})
+	s.BlockBots = sec.Key("BLOCK_BOTS").MustBool(false)
+	if sec.HasKey("BOT_USER_AGENTS") {
+		s.BotUserAgents = nil
+		for _, ua := range sec.Key("BOT_USER_AGENTS").Strings(",") {
+			s.BotUserAgents = append(s.BotUserAgents, strings.ToLower(ua))
+		}
+	}
+	s.AuditLogEnabled = sec.Key("AUDIT_LOG_ENABLED").MustBool(false)
+	s.AuditPublicRepositories = sec.Key("AUDIT_PUBLIC_REPOSITORIES").MustBool(false)
+	s.AuditLogRetention = sec.Key("AUDIT_LOG_RETENTION").MustDuration(s.AuditLogRetention)
+	s.WebhookEnabled = sec.Key("WEBHOOK_ENABLED").MustBool(false)
+	s.ZipAESEnabled = sec.Key("ENABLE_ZIP_AES").MustBool(false)
+	s.SplitPartSize = mustBytes(sec, "SPLIT_PART_SIZE", "2GB")
+	if s.SplitPartSize < SplitMinPartSize {
+		s.SplitPartSize = SplitMinPartSize
+	}
+	s.ArchiveCacheTTL = sec.Key("ARCHIVE_CACHE_TTL").MustDuration(s.ArchiveCacheTTL)
+	s.ArchiveSignaturesEnabled = sec.Key("ENABLE_ARCHIVE_SIGNATURES").MustBool(false)
+	s.PrebuildEnabled = sec.Key("ENABLE_PREBUILD").MustBool(false)
+	s.PrebuildMaxArchives = sec.Key("PREBUILD_MAX_ARCHIVES").MustInt(s.PrebuildMaxArchives)
+	s.DigestMaxSize = mustBytes(sec, "DIGEST_MAX_SIZE", "1MiB")
+	s.VerifyLFSDownloads = sec.Key("VERIFY_LFS_DOWNLOADS").MustBool(s.VerifyLFSDownloads)
+	s.LFSMissingObject = sec.Key("LFS_MISSING_OBJECT").In(s.LFSMissingObject, []string{LFSMissingObjectError, LFSMissingObjectPointer})
+	s.LFSMissingStatus = sec.Key("LFS_MISSING_STATUS").MustInt(s.LFSMissingStatus)
+	if s.LFSMissingStatus != http.StatusNotFound && s.LFSMissingStatus != http.StatusFailedDependency {
+		log.Warn("LFS_MISSING_STATUS must be 404 or 424, not %d", s.LFSMissingStatus)
+		s.LFSMissingStatus = http.StatusNotFound
+	}
+
+	// the export bucket is configured like a minio storage: MINIO_ENDPOINT, MINIO_BUCKET, MINIO_ACCESS_KEY_ID, ...
+	exportSec := setting.CfgProvider.Section("repository.folder_download.export")
+	s.ExportEnabled = exportSec.Key("ENABLED").MustBool(false)
+	s.ExportMaxAttempts = exportSec.Key("MAX_ATTEMPTS").MustInt(s.ExportMaxAttempts)
+	s.ExportStorage.MinioConfig.Location = "us-east-1"
+	if err := exportSec.MapTo(&s.ExportStorage.MinioConfig); err != nil {
+		log.Error("Unable to read [repository.folder_download.export]: %v", err)
+		s.ExportEnabled = false
+	}
+	return s
+})
+
+// mustBytes parses a human readable size like "100MiB", "-1" means no limit.
+// An invalid value falls back to def.
+func mustBytes(sec setting.ConfigSection, key, def string) int64 {
+	value := sec.Key(key).MustString(def)
+	size, err := parseBytes(value)
+	if err != nil {
+		log.Warn("[%s] %s: invalid size %q, using %s: %v", sec.Name(), key, value, def, err)
+		size, _ = parseBytes(def)
+	}
+	return size
+}
+
+func parseBytes(value string) (int64, error) {
+	if value == "-1" {
+		return -1, nil
+	}
+	size, err := humanize.ParseBytes(value)
+	if err != nil {
+		return 0, err
+	}
+	if size > math.MaxInt64 {
+		return 0, errors.New("size is too large")
+	}
+	return int64(size), nil
+}
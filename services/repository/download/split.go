@@ -0,0 +1,154 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"time"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/gitrepo"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/queue"
+	"code.gitea.io/gitea/modules/storage"
+)
+
+const (
+	// splitStatusName keeps the status of a split archive until its index is written
+	splitStatusName = "status.json"
+	// splitStaleAfter is when a queued or running build is assumed lost, for example by a restart
+	splitStaleAfter = time.Hour
+)
+
+// Statuses of a split folder archive build, the archive is done once its index exists
+const (
+	SplitQueued  = "queued"
+	SplitRunning = "running"
+	SplitDone    = "done"
+	SplitFailed  = "failed"
+)
+
+// SplitStatus is the status of a split folder archive build
+type SplitStatus struct {
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// SplitTask asks to build a split folder archive into the archive cache
+type SplitTask struct {
+	RepoID   int64  `json:"repo_id"`
+	Key      string `json:"key"`
+	Commit   string `json:"commit"`
+	Path     string `json:"path"`
+	BaseName string `json:"base_name"`
+	Format   string `json:"format"`
+	Symlinks string `json:"symlinks"`
+	EOL      string `json:"eol"`
+	Mtime    string `json:"mtime"`
+	PartSize int64  `json:"part_size"`
+}
+
+var splitQueue *queue.WorkerPoolQueue[*SplitTask]
+
+// initSplit starts the queue building split folder archives
+func initSplit() error {
+	splitQueue = queue.CreateUniqueQueue(graceful.GetManager().ShutdownContext(), "folder_archive_split", handleSplit)
+	if splitQueue == nil {
+		return errors.New("unable to create folder_archive_split queue")
+	}
+	go graceful.GetManager().RunWithCancel(splitQueue)
+	return nil
+}
+
+func handleSplit(tasks ...*SplitTask) []*SplitTask {
+	ctx := graceful.GetManager().ShutdownContext()
+	for _, task := range tasks {
+		setSplitStatus(task.RepoID, task.Key, SplitRunning, nil)
+		err := runSplit(ctx, task)
+		if err != nil {
+			log.Error("Split folder archive of repository %d at %s failed: %v", task.RepoID, task.Commit, err)
+			setSplitStatus(task.RepoID, task.Key, SplitFailed, err)
+			continue
+		}
+		if err := storage.RepoArchives.Delete(ArchiveCachePath(task.RepoID, task.Key, splitStatusName)); err != nil {
+			log.Error("Unable to delete the status of split folder archive %s: %v", task.Key, err)
+		}
+	}
+	return nil
+}
+
+func runSplit(ctx context.Context, task *SplitTask) error {
+	repo, err := repo_model.GetRepositoryByID(ctx, task.RepoID)
+	if err != nil {
+		return err
+	}
+	gitRepo, err := gitrepo.OpenRepository(ctx, repo)
+	if err != nil {
+		return err
+	}
+	defer gitRepo.Close()
+	commit, err := gitRepo.GetCommit(task.Commit)
+	if err != nil {
+		return err
+	}
+	opts := &ArchiveOptions{Symlinks: task.Symlinks, EOL: task.EOL, Mtime: task.Mtime}
+	_, err = GetOrCreateCachedArchive(ctx, repo, gitRepo, commit, task.Path, task.BaseName, task.Format, opts, task.PartSize)
+	return err
+}
+
+// ReadSplitStatus returns the status of a split archive build, nil if none was queued
+func ReadSplitStatus(repoID int64, key string) (*SplitStatus, error) {
+	obj, err := storage.RepoArchives.Open(ArchiveCachePath(repoID, key, splitStatusName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	status := &SplitStatus{}
+	if err := json.NewDecoder(obj).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// setSplitStatus records the status of a split archive build, an error turns it into SplitFailed
+func setSplitStatus(repoID int64, key, status string, buildErr error) {
+	s := &SplitStatus{Status: status, Updated: time.Now().UTC()}
+	if buildErr != nil {
+		s.Status, s.Error = SplitFailed, buildErr.Error()
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Error("setSplitStatus: %v", err)
+		return
+	}
+	if _, err := storage.RepoArchives.Save(ArchiveCachePath(repoID, key, splitStatusName), strings.NewReader(string(data)), int64(len(data))); err != nil {
+		log.Error("setSplitStatus: %v", err)
+	}
+}
+
+// Stale reports whether a queued or running build is assumed lost
+func (s *SplitStatus) Stale() bool {
+	return time.Since(s.Updated) >= splitStaleAfter
+}
+
+// QueueSplit queues the build of a split archive and records it as queued
+func QueueSplit(task *SplitTask) (*SplitStatus, error) {
+	if splitQueue == nil {
+		return nil, errors.New("the folder_archive_split queue is not running")
+	}
+	setSplitStatus(task.RepoID, task.Key, SplitQueued, nil)
+	if err := splitQueue.Push(task); err != nil && !errors.Is(err, queue.ErrAlreadyInQueue) {
+		setSplitStatus(task.RepoID, task.Key, SplitFailed, err)
+		return nil, err
+	}
+	return &SplitStatus{Status: SplitQueued, Updated: time.Now().UTC()}, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"context"
+	"errors"
+
+	"code.gitea.io/gitea/models/db"
+	access_model "code.gitea.io/gitea/models/perm/access"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	webhook_model "code.gitea.io/gitea/models/webhook"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/optional"
+	"code.gitea.io/gitea/modules/queue"
+	api "code.gitea.io/gitea/modules/structs"
+	webhook_module "code.gitea.io/gitea/modules/webhook"
+	"code.gitea.io/gitea/services/convert"
+	webhook_service "code.gitea.io/gitea/services/webhook"
+)
+
+// Download is a finished download sent to the repository_download webhooks, DoerID is 0 for anonymous downloads
+type Download struct {
+	RepoID int64  `json:"repo_id"`
+	DoerID int64  `json:"doer_id"`
+	Kind   string `json:"kind"`
+	Ref    string `json:"ref"`
+	Commit string `json:"commit"`
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+}
+
+var webhookQueue *queue.WorkerPoolQueue[*Download]
+
+// initWebhook starts the queue firing the repository_download event, if the event is enabled
+func initWebhook() error {
+	if !Setting().WebhookEnabled {
+		return nil
+	}
+	webhookQueue = queue.CreateSimpleQueue(graceful.GetManager().ShutdownContext(), "repository_download_webhook", handleWebhook)
+	if webhookQueue == nil {
+		return errors.New("unable to create repository_download_webhook queue")
+	}
+	go graceful.GetManager().RunWithCancel(webhookQueue)
+	return nil
+}
+
+// QueueDownloadWebhook fires the repository_download event for a download in the background,
+// the webhooks are looked up by the queue so that the download does not wait for them
+func QueueDownloadWebhook(download *Download) {
+	if webhookQueue == nil {
+		return
+	}
+	if err := webhookQueue.Push(download); err != nil {
+		log.Error("Unable to queue the repository_download event of repository %d: %v", download.RepoID, err)
+	}
+}
+
+func handleWebhook(downloads ...*Download) []*Download {
+	ctx := graceful.GetManager().ShutdownContext()
+	for _, download := range downloads {
+		if err := prepareDownloadWebhooks(ctx, download); err != nil {
+			log.Error("Unable to fire the repository_download event of repository %d: %v", download.RepoID, err)
+		}
+	}
+	return nil
+}
+
+// prepareDownloadWebhooks creates the hook tasks of a download. Only Gitea and Gogs webhooks receive it,
+// the chat integrations have no representation for it. Hooks that send everything only receive it when
+// the event is chosen as well, see webhook_model.Webhook.HasEvent.
+func prepareDownloadWebhooks(ctx context.Context, download *Download) error {
+	repo, err := repo_model.GetRepositoryByID(ctx, download.RepoID)
+	if err != nil {
+		if repo_model.IsErrRepoNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	hooks, err := db.Find[webhook_model.Webhook](ctx, webhook_model.ListWebhookOptions{RepoID: repo.ID, IsActive: optional.Some(true)})
+	if err != nil {
+		return err
+	}
+	ownerHooks, err := db.Find[webhook_model.Webhook](ctx, webhook_model.ListWebhookOptions{OwnerID: repo.OwnerID, IsActive: optional.Some(true)})
+	if err != nil {
+		return err
+	}
+	systemHooks, err := webhook_model.GetSystemWebhooks(ctx, optional.Some(true))
+	if err != nil {
+		return err
+	}
+	hooks = append(append(hooks, ownerHooks...), systemHooks...)
+
+	var payload *api.RepositoryDownloadPayload
+	for _, w := range hooks {
+		if (w.Type != webhook_module.GITEA && w.Type != webhook_module.GOGS) || !w.HasEvent(webhook_module.HookEventRepositoryDownload) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = newDownloadPayload(ctx, repo, download); err != nil {
+				return err
+			}
+		}
+		if err := webhook_service.PrepareWebhook(ctx, w, webhook_module.HookEventRepositoryDownload, payload); err != nil {
+			log.Error("PrepareWebhook: %v", err)
+		}
+	}
+	return nil
+}
+
+func newDownloadPayload(ctx context.Context, repo *repo_model.Repository, download *Download) (*api.RepositoryDownloadPayload, error) {
+	var doer *user_model.User
+	if download.DoerID != 0 {
+		var err error
+		if doer, err = user_model.GetPossibleUserByID(ctx, download.DoerID); err != nil && !user_model.IsErrUserNotExist(err) {
+			return nil, err
+		}
+	}
+	var permission access_model.Permission
+	if doer != nil {
+		permission, _ = access_model.GetUserRepoPermission(ctx, repo, doer)
+	}
+	payload := &api.RepositoryDownloadPayload{
+		Kind:       download.Kind,
+		Ref:        download.Ref,
+		Commit:     download.Commit,
+		Path:       download.Path,
+		Format:     download.Format,
+		Size:       download.Size,
+		Repository: convert.ToRepo(ctx, repo, permission),
+	}
+	if doer != nil {
+		payload.Sender = convert.ToUser(ctx, doer, nil)
+	}
+	return payload, nil
+}
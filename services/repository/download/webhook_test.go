@@ -0,0 +1,35 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"testing"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDownloadPayload(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1})
+
+	download := &Download{RepoID: repo.ID, DoerID: 2, Kind: "folder", Ref: "master", Commit: "65f1bf27bc3bf70f64657658635e66094edbcb4d", Path: "docs", Format: "zip", Size: 42}
+	payload, err := newDownloadPayload(t.Context(), repo, download)
+	require.NoError(t, err)
+	assert.Equal(t, "folder", payload.Kind)
+	assert.Equal(t, "docs", payload.Path)
+	assert.EqualValues(t, 42, payload.Size)
+	assert.Equal(t, repo.FullName(), payload.Repository.FullName)
+	require.NotNil(t, payload.Sender)
+	assert.EqualValues(t, 2, payload.Sender.ID)
+
+	// anonymous downloads have no sender
+	download.DoerID = 0
+	payload, err = newDownloadPayload(t.Context(), repo, download)
+	require.NoError(t, err)
+	assert.Nil(t, payload.Sender)
+}
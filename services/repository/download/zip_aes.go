@@ -0,0 +1,194 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"time"
+
+	"code.gitea.io/gitea/modules/git"
+)
+
+// The WinZip AES (AE-2) format, see https://www.winzip.com/en/support/aes-encryption/
+const (
+	zipMethodAES      = 99
+	zipAESExtraID     = 0x9901
+	zipAESVersionAE2  = 2
+	zipAESStrength256 = 3
+	zipAESSaltSize    = 16
+	zipAESKeySize     = 32
+	zipAESVerifySize  = 2
+	zipAESMACSize     = 10
+	zipAESIterations  = 1000
+	zipAESReadVersion = 51
+
+	// zipAESCompressMaxSize is the largest file deflated in memory before encryption, larger ones are stored
+	zipAESCompressMaxSize = 16 * 1024 * 1024
+)
+
+// aesZipEntryWriter writes a zip archive whose entries are encrypted with AES-256
+type aesZipEntryWriter struct {
+	zw       *zip.Writer
+	password string
+}
+
+func (w *aesZipEntryWriter) WriteFile(name string, mode git.EntryMode, modTime time.Time, size int64, r io.Reader) error {
+	fh := &zip.FileHeader{Name: name, UncompressedSize64: uint64(size)}
+	if size > zipAESCompressMaxSize {
+		return w.writeEncrypted(fh, archiveFileMode(mode), modTime, zip.Store, size, r)
+	}
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, r); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	return w.writeEncrypted(fh, archiveFileMode(mode), modTime, zip.Deflate, int64(compressed.Len()), &compressed)
+}
+
+// WriteSymlink stores symlinks unencrypted: like the entry names, their targets are not secret
+// in the zip format, and most extractors cannot restore encrypted symlinks.
+func (w *aesZipEntryWriter) WriteSymlink(name, target string, modTime time.Time) error {
+	return (&zipEntryWriter{zw: w.zw}).WriteSymlink(name, target, modTime)
+}
+
+func (w *aesZipEntryWriter) Close() error {
+	return w.zw.Close()
+}
+
+// writeEncrypted writes an AE-2 entry: salt, password verifier, the AES-CTR encrypted data of size bytes
+// compressed with method, and the truncated HMAC-SHA1 of the encrypted data
+func (w *aesZipEntryWriter) writeEncrypted(fh *zip.FileHeader, mode fs.FileMode, modTime time.Time, method uint16, size int64, r io.Reader) error {
+	salt := make([]byte, zipAESSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	keys, err := pbkdf2.Key(sha1.New, w.password, salt, zipAESIterations, 2*zipAESKeySize+zipAESVerifySize)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(keys[:zipAESKeySize])
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha1.New, keys[zipAESKeySize:2*zipAESKeySize])
+
+	fh.Method = zipMethodAES
+	fh.Flags |= 0x1 // encrypted
+	fh.CreatorVersion = zipAESReadVersion
+	fh.ReaderVersion = zipAESReadVersion
+	fh.CompressedSize64 = uint64(zipAESSaltSize + zipAESVerifySize + size + zipAESMACSize)
+	fh.ModifiedDate, fh.ModifiedTime = msDosTime(modTime)
+	fh.Extra = zipAESExtra(method, modTime)
+	fh.SetMode(mode)
+
+	dst, err := w.zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(salt); err != nil {
+		return err
+	}
+	if _, err := dst.Write(keys[2*zipAESKeySize:]); err != nil {
+		return err
+	}
+
+	stream := newZipAESCTR(block)
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			stream.XORKeyStream(buf[:n], buf[:n])
+			mac.Write(buf[:n])
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			written += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if written != size {
+		return io.ErrUnexpectedEOF
+	}
+	_, err = dst.Write(mac.Sum(nil)[:zipAESMACSize])
+	return err
+}
+
+// zipAESExtra returns the AES extra field followed by the extended timestamp, which CreateRaw does not add
+func zipAESExtra(method uint16, modTime time.Time) []byte {
+	extra := make([]byte, 0, 20)
+	extra = binary.LittleEndian.AppendUint16(extra, zipAESExtraID)
+	extra = binary.LittleEndian.AppendUint16(extra, 7)
+	extra = binary.LittleEndian.AppendUint16(extra, zipAESVersionAE2)
+	extra = append(extra, 'A', 'E', zipAESStrength256)
+	extra = binary.LittleEndian.AppendUint16(extra, method)
+
+	extra = binary.LittleEndian.AppendUint16(extra, 0x5455)
+	extra = binary.LittleEndian.AppendUint16(extra, 5)
+	extra = append(extra, 1) // modification time only
+	return binary.LittleEndian.AppendUint32(extra, uint32(modTime.Unix()))
+}
+
+// msDosTime converts t to the MS-DOS date and time stored in zip headers
+func msDosTime(t time.Time) (date, tm uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tm = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, tm
+}
+
+// zipAESCTR is AES in counter mode with the little-endian counter starting at 1 used by WinZip,
+// crypto/cipher's CTR increments big-endian
+type zipAESCTR struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func newZipAESCTR(block cipher.Block) *zipAESCTR {
+	return &zipAESCTR{block: block, used: aes.BlockSize}
+}
+
+func (c *zipAESCTR) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.stream[c.used]
+		c.used++
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package download
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/modules/git"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readZipAESEntry checks and decrypts an AE-2 entry like an extractor does
+func readZipAESEntry(t *testing.T, f *zip.File, password string) []byte {
+	require.EqualValues(t, zipMethodAES, f.Method)
+	require.NotZero(t, f.Flags&0x1, "encrypted flag")
+	require.GreaterOrEqual(t, len(f.Extra), 11)
+	require.EqualValues(t, zipAESExtraID, binary.LittleEndian.Uint16(f.Extra))
+	require.EqualValues(t, zipAESVersionAE2, binary.LittleEndian.Uint16(f.Extra[4:]))
+	require.Equal(t, "AE", string(f.Extra[6:8]))
+	require.EqualValues(t, zipAESStrength256, f.Extra[8])
+	method := binary.LittleEndian.Uint16(f.Extra[9:])
+
+	rc, err := f.OpenRaw()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.EqualValues(t, f.CompressedSize64, len(raw))
+	salt, verifier := raw[:zipAESSaltSize], raw[zipAESSaltSize:zipAESSaltSize+zipAESVerifySize]
+	data, sum := raw[zipAESSaltSize+zipAESVerifySize:len(raw)-zipAESMACSize], raw[len(raw)-zipAESMACSize:]
+
+	keys, err := pbkdf2.Key(sha1.New, password, salt, zipAESIterations, 2*zipAESKeySize+zipAESVerifySize)
+	require.NoError(t, err)
+	require.Equal(t, keys[2*zipAESKeySize:], verifier, "password verifier")
+	mac := hmac.New(sha1.New, keys[zipAESKeySize:2*zipAESKeySize])
+	mac.Write(data)
+	require.Equal(t, mac.Sum(nil)[:zipAESMACSize], sum, "authentication code")
+
+	block, err := aes.NewCipher(keys[:zipAESKeySize])
+	require.NoError(t, err)
+	plain := make([]byte, len(data))
+	newZipAESCTR(block).XORKeyStream(plain, data)
+	if method == zip.Deflate {
+		plain, err = io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+		require.NoError(t, err)
+	} else {
+		require.EqualValues(t, zip.Store, method)
+	}
+	require.EqualValues(t, f.UncompressedSize64, len(plain))
+	return plain
+}
+
+func TestAESZipEntryWriter(t *testing.T) {
+	modTime := time.Date(2025, 3, 4, 5, 6, 8, 0, time.UTC)
+	small := "hello\n"
+	large := strings.Repeat("folder archive ", 5000)
+
+	var buf bytes.Buffer
+	w := &aesZipEntryWriter{zw: zip.NewWriter(&buf), password: "secret"}
+	require.NoError(t, w.WriteFile("dir/small.txt", git.EntryModeBlob, modTime, int64(len(small)), strings.NewReader(small)))
+	require.NoError(t, w.WriteFile("dir/run.sh", git.EntryModeExec, modTime, int64(len(large)), strings.NewReader(large)))
+	require.NoError(t, w.writeEncrypted(&zip.FileHeader{Name: "stored.txt", UncompressedSize64: uint64(len(small))}, 0o644, modTime, zip.Store, int64(len(small)), strings.NewReader(small)))
+	require.NoError(t, w.WriteSymlink("dir/link", "small.txt", modTime))
+	// a short reader must not produce an entry with a wrong size
+	assert.ErrorIs(t, w.writeEncrypted(&zip.FileHeader{Name: "short.txt"}, 0o644, modTime, zip.Store, 10, strings.NewReader("short")), io.ErrUnexpectedEOF)
+	require.NoError(t, w.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	assert.Equal(t, small, string(readZipAESEntry(t, files["dir/small.txt"], "secret")))
+	assert.Equal(t, large, string(readZipAESEntry(t, files["dir/run.sh"], "secret")))
+	assert.Less(t, files["dir/run.sh"].CompressedSize64, uint64(len(large)), "deflated before encryption")
+	assert.NotZero(t, files["dir/run.sh"].Mode()&0o100, "executable")
+	assert.Equal(t, small, string(readZipAESEntry(t, files["stored.txt"], "secret")))
+	assert.True(t, files["dir/small.txt"].Modified.Equal(modTime), "%v", files["dir/small.txt"].Modified)
+
+	// symlinks are not encrypted
+	link := files["dir/link"]
+	require.NotNil(t, link)
+	assert.NotEqualValues(t, zipMethodAES, link.Method)
+}
+
+func TestZipAESCTR(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, zipAESKeySize)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+
+	// the keystream is the encryption of a little-endian counter starting at 1
+	zero := make([]byte, 3*aes.BlockSize)
+	stream := make([]byte, len(zero))
+	newZipAESCTR(block).XORKeyStream(stream, zero)
+	for i := range 3 {
+		var counter, expected [aes.BlockSize]byte
+		counter[0] = byte(i + 1)
+		block.Encrypt(expected[:], counter[:])
+		assert.Equal(t, expected[:], stream[i*aes.BlockSize:(i+1)*aes.BlockSize], "block %d", i)
+	}
+
+	// the counter carries into the next byte
+	ctr := newZipAESCTR(block)
+	ctr.counter[0] = 0xff
+	out := make([]byte, aes.BlockSize)
+	ctr.XORKeyStream(out, make([]byte, aes.BlockSize))
+	var counter, expected [aes.BlockSize]byte
+	counter[1] = 1
+	block.Encrypt(expected[:], counter[:])
+	assert.Equal(t, expected[:], out)
+
+	// splitting the input does not change the keystream
+	split := make([]byte, len(zero))
+	ctr = newZipAESCTR(block)
+	ctr.XORKeyStream(split[:5], zero[:5])
+	ctr.XORKeyStream(split[5:], zero[5:])
+	assert.Equal(t, stream, split)
+}
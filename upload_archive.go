@@ -0,0 +1,75 @@
+// Copyright 2025 The Gitea Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os/exec"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/services/context"
+)
+
+// ServiceUploadArchive implements the git upload-archive service over smart HTTP,
+// so folders can be exported with "git archive --remote".
+func ServiceUploadArchive(ctx *context.Context) {
+	if ctx.Req.Header.Get("Content-Type") != "application/x-git-upload-archive-request" {
+		ctx.HTTPError(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	args, err := git.ReadUploadArchiveArguments(io.LimitReader(ctx.Req.Body, 64*1024))
+	if err != nil {
+		ctx.HTTPError(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = git.ValidateUploadArchiveArguments(ctx.Repo.GitRepo, args); err != nil {
+		if git.IsErrNotExist(err) {
+			ctx.NotFound(err)
+		} else {
+			ctx.HTTPError(http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	if !checkArchivePolicy(ctx, false) {
+		return
+	}
+	if !throttleDownload(ctx, downloadKindArchive) {
+		return
+	}
+	release := startArchiveJob(ctx)
+	if release == nil {
+		return
+	}
+	defer release()
+
+	var stdin bytes.Buffer
+	_ = git.WriteUploadArchiveArguments(&stdin, args)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "upload-archive", ".")
+	cmd.Dir = ctx.Repo.GitRepo.Path
+	cmd.Stdin = &stdin
+	cmd.Stdout = ctx.Resp
+	cmd.Stderr = &stderr
+
+	ctx.Resp.Header().Set("Content-Type", "application/x-git-upload-archive-result")
+	ctx.Resp.Header().Set("Cache-Control", "no-cache")
+	if err := cmd.Run(); err != nil {
+		log.Error("ServiceUploadArchive: git upload-archive failed in %s: %v - %s", ctx.Repo.Repository.FullName(), err, stderr.String())
+	}
+}
+
+// writeUploadArchiveAdvertisement answers "info/refs?service=git-upload-archive". upload-archive has no refs
+// or capabilities to advertise, the response only tells smart HTTP clients that the service is available.
+func writeUploadArchiveAdvertisement(ctx *context.Context) {
+	ctx.Resp.Header().Set("Content-Type", "application/x-git-upload-archive-advertisement")
+	ctx.Resp.WriteHeader(http.StatusOK)
+	_, _ = ctx.Resp.Write(packetWrite("# service=git-upload-archive\n"))
+	_, _ = ctx.Resp.Write([]byte("0000"))
+}
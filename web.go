@@ -262,6 +262,7 @@ func Routes() *web.Router {
 
 	if setting.Metrics.Enabled {
 		prometheus.MustRegister(metrics.NewCollector())
+		repo.RegisterDownloadMetrics()
 		routes.Get("/metrics", append(mid, Metrics)...)
 	}
 
@@ -682,7 +683,9 @@ func registerWebRoutes(m *web.Router) {
 			m.Get("", user_setting.BlockedUsers)
 			m.Post("", web.Bind(forms.BlockUserForm{}), user_setting.BlockedUsersPost)
 		})
-	}, reqSignIn, ctxDataSet("PageIsUserSettings", true, "EnablePackages", setting.Packages.Enabled, "EnableNotifyMail", setting.Service.EnableNotifyMail))
+
+		m.Get("/download_usage", repo.DownloadUsageSettings)
+	}, reqSignIn, ctxDataSet("PageIsUserSettings", true, "EnablePackages", setting.Packages.Enabled, "EnableNotifyMail", setting.Service.EnableNotifyMail, "EnableDownloadUsage", repo.DownloadUsageEnabled()))
 
 	m.Group("/user", func() {
 		m.Get("/activate", auth.Activate)
@@ -716,6 +719,10 @@ func registerWebRoutes(m *web.Router) {
 		m.Get("/self_check", admin.SelfCheck)
 		m.Post("/self_check", admin.SelfCheckPost)
 
+		m.Get("/download_audit", repo.DownloadAuditExport)
+		m.Get("/download_usage", repo.DownloadUsageReport)
+		m.Combo("/lfs_storage").Get(repo.LFSStorageScan).Post(repo.LFSStorageScanPost)
+
 		m.Group("/config", func() {
 			m.Get("", admin.Config)
 			m.Post("", admin.ChangeConfig)
@@ -814,7 +821,7 @@ func registerWebRoutes(m *web.Router) {
 			addSettingsRunnersRoutes()
 			addSettingsVariablesRoutes()
 		})
-	}, adminReq, ctxDataSet("EnableOAuth2", setting.OAuth2.Enabled, "EnablePackages", setting.Packages.Enabled))
+	}, adminReq, ctxDataSet("EnableOAuth2", setting.OAuth2.Enabled, "EnablePackages", setting.Packages.Enabled, "DownloadAuditEnabled", repo.DownloadAuditEnabled(), "LFSStartServer", setting.LFS.StartServer))
 	// ***** END: Admin *****
 
 	m.Group("", func() {
@@ -1082,6 +1089,8 @@ func registerWebRoutes(m *web.Router) {
 		m.Post("/avatar/delete", repo_setting.SettingsDeleteAvatar)
 
 		m.Combo("/public_access").Get(repo_setting.PublicAccess).Post(repo_setting.PublicAccessPost)
+		m.Get("/download_audit", repo.DownloadAuditExport)
+		m.Combo("/archives").Get(repo.FolderArchivePrebuilds).Post(repo.FolderArchivePrebuildsPost)
 
 		m.Group("/collaboration", func() {
 			m.Combo("").Get(repo_setting.Collaboration).Post(repo_setting.CollaborationPost)
@@ -1163,12 +1172,12 @@ func registerWebRoutes(m *web.Router) {
 		})
 	},
 		reqSignIn, context.RepoAssignment, reqRepoAdmin,
-		ctxDataSet("PageIsRepoSettings", true, "LFSStartServer", setting.LFS.StartServer),
+		ctxDataSet("PageIsRepoSettings", true, "LFSStartServer", setting.LFS.StartServer, "DownloadAuditEnabled", repo.DownloadAuditEnabled()),
 	)
 	// end "/{username}/{reponame}/settings"
 
 	// user/org home, including rss feeds like "/{username}/{reponame}.rss"
-	m.Get("/{username}/{reponame}", optSignIn, context.RepoAssignment, context.RepoRefByType(git.RefTypeBranch), repo.SetEditorconfigIfExists, repo.Home)
+	m.Get("/{username}/{reponame}", optSignIn, context.RepoAssignment, context.RepoRefByType(git.RefTypeBranch), repo.SetEditorconfigIfExists, repo.SetFolderDownloadData, repo.Home)
 
 	m.Post("/{username}/{reponame}/markup", optSignIn, context.RepoAssignment, reqUnitsWithMarkdown, web.Bind(structs.MarkupOption{}), misc.Markup)
 
@@ -1386,8 +1395,8 @@ func registerWebRoutes(m *web.Router) {
 			m.Get("/tag/*", repo.SingleRelease)
 			m.Get("/latest", repo.LatestRelease)
 		}, ctxDataSet("EnableFeed", setting.Other.EnableFeed))
-		m.Get("/releases/attachments/{uuid}", repo.GetAttachment)
-		m.Get("/releases/download/{vTag}/{fileName}", repo.RedirectDownload)
+		m.Get("/releases/attachments/{uuid}", repo.ObserveRepoDownload("release"), repo.GetAttachment)
+		m.Get("/releases/download/{vTag}/{fileName}", repo.ObserveRepoDownload("release"), repo.RedirectDownload)
 		m.Group("/releases", func() {
 			m.Get("/new", repo.NewRelease)
 			m.Post("/new", web.Bind(forms.NewReleaseForm{}), repo.NewReleasePost)
@@ -1554,13 +1563,16 @@ func registerWebRoutes(m *web.Router) {
 			m.Get("/{period}", repo.ActivityAuthors)
 		}, repo.MustBeNotEmpty)
 
-		m.Get("/download/folder/*", repo.MustBeNotEmpty, repo.DownloadFolder)
-		m.Get("/download/folder/branch/{branchname}/*", repo.MustBeNotEmpty, repo.DownloadFolder)
+		m.Combo("/download/folder/*", repo.MustBeNotEmpty, repo.MustAllowBulkDownload).Get(repo.DownloadFolder).Post(repo.DownloadFolderPost)
+		m.Combo("/download/folder/branch/{branchname}/*", repo.MustBeNotEmpty, repo.MustAllowBulkDownload).Get(repo.DownloadFolder).Post(repo.DownloadFolderPost)
+		m.Get("/download/folder-part/{key:[a-f0-9]{64}}/{name}", repo.MustBeNotEmpty, repo.MustAllowBulkDownload, repo.DownloadFolderPart)
+		m.Get("/download/folder-status/{key:[a-f0-9]{64}}", repo.MustBeNotEmpty, repo.MustAllowBulkDownload, repo.FolderArchiveSplitStatusJSON)
+		m.Combo("/download/tree/{sha:([a-f0-9]{40}|[a-f0-9]{64})}", repo.MustBeNotEmpty, repo.MustAllowBulkDownload).Get(repo.DownloadTreeByID).Post(repo.DownloadArchiveCaptchaPost)
 
 		m.Group("/archive", func() {
-			m.Get("/*", repo.Download)
+			m.Get("/*", repo.ObserveRepoDownload("archive"), repo.Download)
 			m.Post("/*", repo.InitiateDownload)
-		}, repo.MustBeNotEmpty, dlSourceEnabled)
+		}, repo.MustBeNotEmpty, dlSourceEnabled, repo.MustAllowBulkDownload)
 
 		m.Group("/branches", func() {
 			m.Get("/list", repo.GetBranchesList)
@@ -1623,7 +1635,7 @@ func registerWebRoutes(m *web.Router) {
 			m.Get("/tag/*", context.RepoRefByType(git.RefTypeTag), repo.Home)
 			m.Get("/commit/*", context.RepoRefByType(git.RefTypeCommit), repo.Home)
 			m.Get("/*", context.RepoRefByType(""), repo.Home) // "/*" route is deprecated, and kept for backward compatibility
-		}, repo.SetEditorconfigIfExists)
+		}, repo.SetEditorconfigIfExists, repo.SetFolderDownloadData)
 		m.Get("/tree/*", repo.RedirectRepoTreeToSrc)    // redirect "/owner/repo/tree/*" requests to "/owner/repo/src/*"
 		m.Get("/blob/*", repo.RedirectRepoBlobToCommit) // redirect "/owner/repo/blob/*" requests to "/owner/repo/src/commit/*"
 
@@ -1646,6 +1658,10 @@ func registerWebRoutes(m *web.Router) {
 
 	addOwnerRepoGitHTTPRouters(m) // "/{username}/{reponame}/{git-paths}": git http support
 
+	m.Group("/{username}/{reponame}", func() {
+		m.Methods("POST,OPTIONS", "/git-upload-archive", repo.ServiceUploadArchive)
+	}, optSignInIgnoreCsrf, repo.HTTPGitEnabledHandler, repo.CorsHandler(), context.RepoAssignment, reqUnitCodeReader, repo.MustBeNotEmpty, repo.MustAllowBulkDownload)
+
 	m.Group("/notifications", func() {
 		m.Get("", user.Notifications)
 		m.Get("/subscriptions", user.NotificationSubscriptions)
@@ -0,0 +1,73 @@
+import {GET} from '../modules/fetch.ts';
+import {fomanticQuery} from '../modules/fomantic/base.ts';
+import {addDelegatedEventListener, hideElem, showElem} from '../utils/dom.ts';
+
+type FolderSummary = {
+  confirm_required: boolean;
+  largest_files?: Array<{path: string, size_human: string}>;
+  [field: string]: unknown;
+};
+
+async function fetchFolderSummary(url: string): Promise<FolderSummary | null> {
+  try {
+    const resp = await GET(url);
+    return resp.ok ? await resp.json() : null;
+  } catch {
+    return null; // the download must not be blocked by a failing summary
+  }
+}
+
+// ask for a confirmation before downloading a folder which the summary reports as large
+export function initRepoDownloadFolderConfirm() {
+  addDelegatedEventListener(document, 'click', '.repo-download-folder-link', async (link: HTMLAnchorElement, e: MouseEvent) => {
+    e.preventDefault();
+    const modal = document.querySelector('#repo-download-folder-confirm');
+    const summary = await fetchFolderSummary(link.getAttribute('data-summary-url'));
+    if (!summary?.confirm_required || !modal) {
+      window.location.href = link.href;
+      return;
+    }
+    for (const el of modal.querySelectorAll('[data-summary-field]')) {
+      el.textContent = String(summary[el.getAttribute('data-summary-field')] ?? '');
+    }
+    modal.querySelector('.repo-download-folder-largest').replaceChildren(...(summary.largest_files ?? []).map((file) => {
+      const li = document.createElement('li');
+      li.textContent = `${file.path} (${file.size_human})`;
+      return li;
+    }));
+    fomanticQuery(modal).modal({
+      onApprove: () => {
+        window.location.href = link.href;
+      },
+    }).modal('show');
+  });
+}
+
+// poll the build of a split folder archive and show its parts once it is done
+export function initRepoDownloadFolderParts() {
+  const el = document.querySelector('#repo-download-folder-parts-status');
+  if (!el || !el.querySelector('.repo-download-folder-parts-failed.tw-hidden')) return;
+
+  const poll = async () => {
+    try {
+      const resp = await GET(el.getAttribute('data-status-url'));
+      if (resp.ok) {
+        const data: {status: string, error?: string} = await resp.json();
+        if (data.status === 'done') {
+          window.location.reload();
+          return;
+        }
+        if (data.status === 'failed') {
+          el.querySelector('.repo-download-folder-parts-error').textContent = data.error ?? '';
+          hideElem(el.querySelector('.repo-download-folder-parts-pending'));
+          showElem(el.querySelector('.repo-download-folder-parts-failed'));
+          return;
+        }
+      }
+    } catch {
+      // keep polling through network errors
+    }
+    setTimeout(poll, 2000);
+  };
+  setTimeout(poll, 2000);
+}